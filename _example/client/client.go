@@ -23,29 +23,16 @@ func main() {
 		log.Panicln(err)
 	}
 
-	var req, res p2p.Data
+	var buy Buy
 
 	for i := 0; i < 10; i++ {
-		req = p2p.Data{}
-		err = req.SetGob(Hello{
+		buy, err = p2p.Call[Hello, Buy](client, "dialog", Hello{
 			Text: fmt.Sprintf("User #%d", i+1),
 		})
 		if err != nil {
 			log.Panicln(err)
 		}
 
-		res = p2p.Data{}
-		res, err = client.Send("dialog", req)
-		if err != nil {
-			log.Panicln(err)
-		}
-
-		var buy Buy
-		err = res.GetGob(&buy)
-		if err != nil {
-			log.Panicln(err)
-		}
-
 		fmt.Printf("> Buy: %s\n", buy.Text)
 	}
 }
@@ -24,19 +24,12 @@ func main() {
 		log.Panicln(err)
 	}
 
-	server.SetHandler("dialog", func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
-		hello := Hello{}
-		err = req.GetGob(&hello)
-		if err != nil {
-			return
-		}
-
+	p2p.HandleTyped(server, "dialog", func(ctx context.Context, hello Hello) (buy Buy, err error) {
 		fmt.Printf("> Hello: %s\n", hello.Text)
 
-		res = p2p.Data{}
-		err = res.SetGob(Buy{
+		buy = Buy{
 			Text: hello.Text,
-		})
+		}
 
 		return
 	})
@@ -0,0 +1,36 @@
+package p2p
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	minAcceptDelay = 5 * time.Millisecond
+	maxAcceptDelay = time.Second
+)
+
+type ErrorHandler func(err error)
+
+// isTemporary reports errors that net/http also retries to accept after.
+func isTemporary(err error) (ok bool) {
+	var te interface {
+		Temporary() bool
+	}
+
+	return errors.As(err, &te) && te.Temporary()
+}
+
+// backoff doubles the delay between accept retries.
+func backoff(delay time.Duration) (next time.Duration) {
+	if delay == 0 {
+		return minAcceptDelay
+	}
+
+	next = delay * 2
+	if next > maxAcceptDelay {
+		next = maxAcceptDelay
+	}
+
+	return
+}
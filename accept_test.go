@@ -0,0 +1,182 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type temporaryError struct{}
+
+func (temporaryError) Error() (str string) {
+	return "temporary accept error"
+}
+
+func (temporaryError) Temporary() (ok bool) {
+	return true
+}
+
+type deadlineConn struct {
+	net.Conn
+}
+
+func (deadlineConn) SetDeadline(time.Time) (err error) {
+	return errors.New("deadline is not supported")
+}
+
+// faultListener returns its faults before accepted connections and breaks deadlines of bad connections.
+type faultListener struct {
+	net.Listener
+
+	mx       sync.Mutex
+	faults   []error
+	badConns int
+}
+
+func (l *faultListener) Accept() (conn net.Conn, err error) {
+	l.mx.Lock()
+	if len(l.faults) > 0 {
+		err = l.faults[0]
+		l.faults = l.faults[1:]
+		l.mx.Unlock()
+
+		return
+	}
+	l.mx.Unlock()
+
+	conn, err = l.Listener.Accept()
+	if err != nil {
+		return
+	}
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if l.badConns > 0 {
+		l.badConns--
+		conn = deadlineConn{
+			Conn: conn,
+		}
+	}
+
+	return
+}
+
+func TestServeListenerFaults(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener := &faultListener{
+		Listener: inner,
+		faults:   []error{temporaryError{}, temporaryError{}},
+		badConns: 1,
+	}
+
+	host, port, err := net.SplitHostPort(inner.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := NewServer(NewTCP(host, port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	server.SetHandler("ping", func(ctx context.Context, req Data) (res Data, err error) {
+		return
+	})
+
+	errs := make(chan error, 8)
+	server.SetErrorHandler(func(err error) {
+		errs <- err
+	})
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.ServeListener(listener)
+	}()
+
+	// the bad connection is dropped without an answer
+	conn, err := net.Dial("tcp", inner.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = conn.Read(make([]byte, 1))
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("closed connection is expected, got %v", err)
+	}
+
+	_ = conn.Close()
+
+	client, err := NewClient(newTestTCP(t, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	_, err = client.Send("ping", Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []error{temporaryError{}, temporaryError{}, PresetConnectionError} {
+		select {
+		case err = <-errs:
+		case <-time.After(time.Second):
+			t.Fatal("error handler is not called")
+		}
+
+		if !errors.Is(err, expected) {
+			t.Fatalf("error %v is expected, got %v", expected, err)
+		}
+	}
+
+	// a permanent error stops serving
+	err = inner.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err = <-served:
+	case <-time.After(time.Second):
+		t.Fatal("serving isn't stopped")
+	}
+
+	if !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("closed listener error is expected, got %v", err)
+	}
+
+	select {
+	case err = <-errs:
+	case <-time.After(time.Second):
+		t.Fatal("error handler is not called")
+	}
+
+	if !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("closed listener error is expected, got %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	var delay time.Duration
+	for _, expected := range []time.Duration{5, 10, 20, 40, 80, 160, 320, 640, 1000, 1000} {
+		delay = backoff(delay)
+		if delay != expected*time.Millisecond {
+			t.Fatalf("delay %v is expected, got %v", expected*time.Millisecond, delay)
+		}
+	}
+}
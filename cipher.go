@@ -28,47 +28,99 @@ func NewCipherKey() (key CipherKey, err error) {
 	return
 }
 
-func (key CipherKey) Encode(bs []byte) (rs []byte, err error) {
+func (key CipherKey) aead() (gcm cipher.AEAD, err error) {
 	var block cipher.Block
 	block, err = aes.NewCipher(key)
 	if err != nil {
 		return
 	}
 
-	var gcm cipher.AEAD
 	gcm, err = cipher.NewGCM(block)
+
+	return
+}
+
+func (key CipherKey) Encode(bs []byte) (rs []byte, err error) {
+	var gcm cipher.AEAD
+	gcm, err = key.aead()
 	if err != nil {
 		return
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	_, err = io.ReadFull(rand.Reader, nonce)
+	return seal(gcm, bs)
+}
+
+func (key CipherKey) Decode(bs []byte) (rs []byte, err error) {
+	var gcm cipher.AEAD
+	gcm, err = key.aead()
 	if err != nil {
 		return
 	}
 
-	rs = gcm.Seal(nonce, nonce, bs, nil)
+	return unseal(gcm, bs, false)
+}
 
-	return
+// sessionKey is a cipher key of a connection owner with its AEAD, so the AEAD is made once per key
+// and is dropped together with the key.
+type sessionKey struct {
+	key CipherKey
+	gcm cipher.AEAD
 }
 
-func (key CipherKey) Decode(bs []byte) (rs []byte, err error) {
-	var block cipher.Block
-	block, err = aes.NewCipher(key)
+func newSessionKey(key CipherKey) (sk *sessionKey, err error) {
+	var gcm cipher.AEAD
+	gcm, err = key.aead()
 	if err != nil {
 		return
 	}
 
-	var gcm cipher.AEAD
-	gcm, err = cipher.NewGCM(block)
+	sk = &sessionKey{
+		key: key,
+		gcm: gcm,
+	}
+
+	return
+}
+
+func (sk *sessionKey) encode(bs []byte) (rs []byte, err error) {
+	return seal(sk.gcm, bs)
+}
+
+// open decrypts bs in place, so bs can't be used afterwards.
+func (sk *sessionKey) open(bs []byte) (rs []byte, err error) {
+	return unseal(sk.gcm, bs, true)
+}
+
+func seal(gcm cipher.AEAD, bs []byte) (rs []byte, err error) {
+	nonceSize := gcm.NonceSize()
+
+	rs = make([]byte, nonceSize, nonceSize+len(bs)+gcm.Overhead())
+	_, err = io.ReadFull(rand.Reader, rs)
 	if err != nil {
 		return
 	}
 
+	rs = gcm.Seal(rs, rs, bs, nil)
+
+	return
+}
+
+func unseal(gcm cipher.AEAD, bs []byte, inPlace bool) (rs []byte, err error) {
 	nonceSize := gcm.NonceSize()
+	if len(bs) < nonceSize {
+		err = CipherTextError
+
+		return
+	}
+
 	nonce, cipherText := bs[:nonceSize], bs[nonceSize:]
 
-	rs, err = gcm.Open(nil, nonce, cipherText, nil)
+	var dst []byte
+	if inPlace {
+		dst = cipherText[:0]
+	}
+
+	rs, err = gcm.Open(dst, nonce, cipherText, nil)
 
 	return
 }
@@ -29,6 +29,45 @@ func TestCipher(t *testing.T) {
 	}
 }
 
+func TestCipherOpen(t *testing.T) {
+	origin := []byte("a special secret message")
+
+	ck, err := NewCipherKey()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	sk, err := newSessionKey(ck)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var encoded []byte
+	encoded, err = sk.encode(origin)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var decoded []byte
+	decoded, err = sk.open(encoded)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if string(origin) != string(decoded) {
+		t.Fatal("Origin and Decoded are not equal")
+	}
+
+	if &decoded[0] != &encoded[12] {
+		t.Fatal("Cipher text is not opened in place")
+	}
+
+	_, err = ck.Decode(encoded[:4])
+	if err != CipherTextError {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
 func BenchmarkNewCipher(b *testing.B) {
 	var err error
 
@@ -48,6 +87,9 @@ func BenchmarkCipherEncode(b *testing.B) {
 		b.Fatal(err.Error())
 	}
 
+	b.ReportAllocs()
+	b.ResetTimer()
+
 	for i := 0; i < b.N; i++ {
 		_, err = ck.Encode(origin)
 		if err != nil {
@@ -70,6 +112,9 @@ func BenchmarkCipherDecode(b *testing.B) {
 		b.Fatal(err.Error())
 	}
 
+	b.ReportAllocs()
+	b.ResetTimer()
+
 	for i := 0; i < b.N; i++ {
 		_, err = ck.Decode(encoded)
 		if err != nil {
@@ -77,3 +122,160 @@ func BenchmarkCipherDecode(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkCipherEncodeParallel(b *testing.B) {
+	origin := []byte("a special secret message")
+
+	ck, err := NewCipherKey()
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := ck.Encode(origin)
+			if err != nil {
+				b.Fatal(err.Error())
+			}
+		}
+	})
+}
+
+func BenchmarkCipherDecodeParallel(b *testing.B) {
+	origin := []byte("a special secret message")
+
+	ck, err := NewCipherKey()
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	var encoded []byte
+	encoded, err = ck.Encode(origin)
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := ck.Decode(encoded)
+			if err != nil {
+				b.Fatal(err.Error())
+			}
+		}
+	})
+}
+
+// Session key benchmarks reuse the AEAD of the key, cipher key ones make it on every call.
+func BenchmarkSessionKeyEncode(b *testing.B) {
+	origin := []byte("a special secret message")
+
+	ck, err := NewCipherKey()
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	sk, err := newSessionKey(ck)
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err = sk.encode(origin)
+		if err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+}
+
+func BenchmarkSessionKeyDecode(b *testing.B) {
+	origin := []byte("a special secret message")
+
+	ck, err := NewCipherKey()
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	sk, err := newSessionKey(ck)
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	var encoded []byte
+	encoded, err = sk.encode(origin)
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err = unseal(sk.gcm, encoded, false)
+		if err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+}
+
+func BenchmarkSessionKeyEncodeParallel(b *testing.B) {
+	origin := []byte("a special secret message")
+
+	ck, err := NewCipherKey()
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	sk, err := newSessionKey(ck)
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := sk.encode(origin)
+			if err != nil {
+				b.Fatal(err.Error())
+			}
+		}
+	})
+}
+
+func BenchmarkSessionKeyDecodeParallel(b *testing.B) {
+	origin := []byte("a special secret message")
+
+	ck, err := NewCipherKey()
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	sk, err := newSessionKey(ck)
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	var encoded []byte
+	encoded, err = sk.encode(origin)
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := unseal(sk.gcm, encoded, false)
+			if err != nil {
+				b.Fatal(err.Error())
+			}
+		}
+	})
+}
@@ -1,6 +1,8 @@
 package p2p
 
 import (
+	"context"
+	"errors"
 	"net"
 	"sync"
 	"time"
@@ -11,17 +13,35 @@ type Client struct {
 	rsa *RSA
 
 	settings *ClientSettings
-	logger   Logger
+	level    *levelVar
+	logger   fieldLogger
 
-	mx sync.RWMutex
+	mx     sync.RWMutex
+	router *Router
+
+	instruments *instruments
+	observer    Observer
+	tracer      Tracer
+	hooks       Hooks
+	rates       *rateLimiter
+
+	connSeq uint64
 }
 
 func NewClient(tcp *TCP) (c *Client, err error) {
+	level := newLevelVar(DefaultLogLevel)
+
 	c = &Client{
 		tcp:    tcp,
-		logger: NewStdLogger(),
+		level:  level,
+		logger: newFieldLogger(NewStdLogger()).withLevel(level),
+
+		mx:     sync.RWMutex{},
+		router: NewRouter(),
 
-		mx: sync.RWMutex{},
+		instruments: newInstruments(ClientRole),
+		tracer:      nopTracer{},
+		rates:       newRateLimiter(),
 	}
 
 	c.settings = NewClientSettings()
@@ -33,22 +53,156 @@ func NewClient(tcp *TCP) (c *Client, err error) {
 
 func (c *Client) SetSettings(settings *ClientSettings) {
 	c.settings = settings
+	c.level.set(settings.level)
 }
 
 func (c *Client) SetLogger(logger Logger) {
-	c.logger = logger
+	c.logger = newFieldLogger(logger).withLevel(c.level)
+}
+
+func (c *Client) Collector() (collector Collector) {
+	return c.instruments
+}
+
+func (c *Client) SetObserver(observer Observer) {
+	c.mx.Lock()
+	c.observer = observer
+	c.mx.Unlock()
+}
+
+func (c *Client) SetTracer(tracer Tracer) {
+	if tracer == nil {
+		tracer = nopTracer{}
+	}
+
+	c.mx.Lock()
+	c.tracer = tracer
+	c.mx.Unlock()
+}
+
+func (c *Client) getTracer() (tracer Tracer) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	return c.tracer
+}
+
+func (c *Client) SetHooks(hooks Hooks) {
+	c.mx.Lock()
+	c.hooks = hooks
+	c.mx.Unlock()
+}
+
+func (c *Client) getHooks() (hooks Hooks) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	return c.hooks
+}
+
+func (c *Client) observe(metrics *Metrics, conn *Conn, err error) {
+	metrics.setBytes(conn)
+
+	c.instruments.end(metrics, err)
+
+	c.mx.RLock()
+	observer := c.observer
+	hooks := c.hooks
+	c.mx.RUnlock()
+
+	if observer == nil && hooks.Exchange == nil {
+		return
+	}
+
+	stats := metrics.stats(ClientRole, err)
+
+	if observer != nil {
+		observer(stats)
+	}
+
+	hooks.exchange(conn.info, stats)
+}
+
+func (c *Client) SetHandler(topic string, handler Handler) {
+	c.router.SetHandler(topic, handler)
+}
+
+func (c *Client) setSchema(topic string, schema Schema) {
+	c.router.setSchema(topic, schema)
+}
+
+func (c *Client) RemoveHandler(topic string) (ok bool) {
+	return c.router.RemoveHandler(topic)
+}
+
+func (c *Client) Handlers() (topics []string) {
+	return c.router.Handlers()
+}
+
+func (c *Client) Group(prefix string) (g *Group) {
+	return c.router.Group(prefix)
+}
+
+func (c *Client) SetDefaultHandler(handler Handler) {
+	c.router.SetDefaultHandler(handler)
 }
 
 func (c *Client) Send(topic string, req Data) (res Data, err error) {
+	res, _, err = c.SendWithMetadata(topic, req, nil)
+
+	return
+}
+
+func (c *Client) SendWithMetadata(topic string, req Data, md Metadata) (res Data, resMD Metadata, err error) {
+	return c.SendContext(context.Background(), topic, req, md)
+}
+
+// SendContext sends a request as a part of ctx: it starts a client span with a parent span from ctx,
+// doesn't retry after ctx is done or when a retry delay exceeds the ctx deadline
+// and doesn't wait for a response after ctx is done.
+func (c *Client) SendContext(ctx context.Context, topic string, req Data, md Metadata) (res Data, resMD Metadata, err error) {
+	md = md.Copy()
+	if md == nil {
+		md = Metadata{}
+	}
+
+	var span Span
+	ctx, span = c.getTracer().Start(ctx, ClientSpan, topic, md)
+	defer func() {
+		span.End(err)
+	}()
+
 	var retries = c.settings.retries
 	for retries > 0 {
 		c.mx.RLock()
 		factor := c.settings.retries - retries
 		c.mx.RUnlock()
-		time.Sleep(time.Duration(factor) * c.settings.delay)
+
+		// a rate limiting server tells how long to wait
+		delay := time.Duration(factor) * c.settings.delay
+		if wait := retryAfter(err); wait > delay {
+			delay = wait
+		}
+
+		// a retry after the deadline is useless, so the last error is returned at once
+		deadline, ok := ctx.Deadline()
+		if ok && err != nil && time.Until(deadline) < delay {
+			return
+		}
+
 		retries--
 
-		res, err = c.try(topic, req)
+		err = sleep(ctx, delay)
+		if err != nil {
+			return
+		}
+
+		err = c.wait(ctx, topic)
+		if err != nil {
+			return
+		}
+
+		res, resMD, err = c.try(ctx, span, topic, req, md)
 		if err != nil {
 			continue
 		}
@@ -59,7 +213,30 @@ func (c *Client) Send(topic string, req Data) (res Data, err error) {
 	return
 }
 
-func (c *Client) try(topic string, req Data) (res Data, err error) {
+// wait waits until a request of the topic fits the client rate limits.
+func (c *Client) wait(ctx context.Context, topic string) (err error) {
+	return sleep(ctx, c.rates.reserve(topic, c.settings.RateLimits))
+}
+
+// sleep waits for the delay or until ctx is done.
+func sleep(ctx context.Context, delay time.Duration) (err error) {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	return
+}
+
+func (c *Client) try(ctx context.Context, span Span, topic string, req Data, md Metadata) (res Data, resMD Metadata, err error) {
 	var conn net.Conn
 	conn, err = net.Dial("tcp", c.tcp.addr)
 	if err != nil {
@@ -70,47 +247,99 @@ func (c *Client) try(topic string, req Data) (res Data, err error) {
 		return
 	}
 
+	hooks := c.getHooks()
+
+	var wrapped *Conn
 	defer func() {
-		err := conn.Close()
-		if err != nil {
-			c.logger.Error(err.Error())
+		// a canceled ctx closes the connection before
+		cerr := conn.Close()
+		if cerr != nil && !errors.Is(cerr, net.ErrClosed) {
+			c.logger.Error(cerr.Error())
+		}
+
+		if wrapped != nil {
+			hooks.connClosed(wrapped.info, err)
 		}
 	}()
 
-	var wrapped Conn
-	wrapped, err = NewConn(conn, c.settings.Limiter)
+	wrapped, err = c.wrap(conn)
 	if err != nil {
 		c.logger.Error(err.Error())
 
+		wrapped = nil
+
 		return
 	}
 
+	hooks.connOpened(wrapped.info)
+
+	deadline, ok := ctx.Deadline()
+	if ok {
+		wrapped.limit(deadline)
+	}
+
+	// a canceled ctx interrupts reading and writing
+	if ctx.Done() != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+
+		go func() {
+			select {
+			case <-ctx.Done():
+				_ = conn.Close()
+			case <-stop:
+			}
+		}()
+	}
+
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	}()
+
 	metrics := newMetrics(conn.RemoteAddr().String())
 	metrics.setTopic(topic)
+	metrics.setSpan(span)
+
+	c.instruments.begin()
+	defer func() {
+		c.observe(metrics, wrapped, err)
+	}()
 
 	msg := Message{
-		Topic:   topic,
-		Content: req.GetBytes(),
+		Topic:    topic,
+		Metadata: md,
 	}
+	msg.setData(req)
 
 	for {
 		if c.tcp.cipherKey == nil {
-			var ck CipherKey
-			ck, err = c.doHandshake(wrapped, metrics)
+			var sk *sessionKey
+			sk, c.tcp.session, err = c.doHandshake(wrapped, metrics)
 			if err != nil {
 				break
 			}
 
-			c.tcp.cipherKey = &ck
+			c.tcp.cipherKey = sk
+
+			hooks.handshake(HandshakeInfo{
+				ConnInfo: wrapped.info,
+				Session:  c.tcp.session,
+			})
 		} else {
 			msg, err = c.doExchange(wrapped, metrics, msg)
 			if err != nil {
-				c.tcp.cipherKey = nil
+				// an overloaded, rate limiting or too big decompressed request keeps the cipher key, so a retry doesn't need a new handshake
+				if !keepsSession(err) {
+					c.tcp.cipherKey = nil
+				}
 
 				return
 			}
 
-			res.SetBytes(msg.Content)
+			res = msg.data()
+			resMD = msg.Metadata
 
 			break
 		}
@@ -120,17 +349,121 @@ func (c *Client) try(topic string, req Data) (res Data, err error) {
 		}
 	}
 
-	c.logger.Info(metrics.string())
+	c.logger.access(metrics, c.settings.sampling)
 
 	return
 }
 
-func (c *Client) doHandshake(conn Conn, metrics *Metrics) (ck CipherKey, err error) {
+func (c *Client) Link() (peer *Peer, err error) {
+	var conn net.Conn
+	conn, err = net.Dial("tcp", c.tcp.addr)
+	if err != nil {
+		c.logger.Error(err.Error())
+
+		err = ConnectionError
+
+		return
+	}
+
+	hooks := c.getHooks()
+
+	var wrapped *Conn
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		cerr := conn.Close()
+		if cerr != nil {
+			c.logger.Error(cerr.Error())
+		}
+
+		if wrapped != nil {
+			hooks.connClosed(wrapped.info, err)
+		}
+	}()
+
+	wrapped, err = c.wrap(conn)
+	if err != nil {
+		c.logger.Error(err.Error())
+
+		wrapped = nil
+
+		return
+	}
+
+	hooks.connOpened(wrapped.info)
+
+	metrics := newMetrics(conn.RemoteAddr().String())
+
+	if c.tcp.cipherKey == nil {
+		var sk *sessionKey
+		sk, c.tcp.session, err = c.doHandshake(wrapped, metrics)
+		if err != nil {
+			return
+		}
+
+		c.tcp.cipherKey = sk
+
+		hooks.handshake(HandshakeInfo{
+			ConnInfo: wrapped.info,
+			Session:  c.tcp.session,
+		})
+	}
+
+	peer, err = c.doLink(wrapped)
+	if err != nil {
+		c.tcp.cipherKey = nil
+
+		return
+	}
+
+	go func() {
+		reason := peer.serve()
+		if reason != nil {
+			c.logger.Error(reason.Error())
+		}
+
+		err := peer.Close()
+		if err != nil {
+			c.logger.Error(err.Error())
+		}
+
+		hooks.connClosed(wrapped.info, reason)
+	}()
+
+	return
+}
+
+func (c *Client) wrap(conn net.Conn) (wrapped *Conn, err error) {
+	wire, ok := GetWireCodec(c.settings.wire)
+	if !ok {
+		err = UnsupportedWire
+
+		return
+	}
+
+	wrapped, err = NewConn(conn, c.settings.Limiter)
+	if err != nil {
+		return
+	}
+
+	wrapped.SetWireCodec(wire)
+	wrapped.setLegacy(c.tcp.session.Capabilities)
+	wrapped.info = newConnInfo(&c.connSeq, ClientRole, wrapped)
+
+	return
+}
+
+func (c *Client) doHandshake(conn *Conn, metrics *Metrics) (sk *sessionKey, session Session, err error) {
+	conn.beginHandshake()
+	defer conn.endHandshake()
+
 	p := Package{
 		Type: Handshake,
 	}
 
-	err = p.SetGob(c.rsa.PublicKey())
+	err = conn.setPayload(&p, newHello(c.rsa.PublicKey()))
 	if err != nil {
 		c.logger.Error(err.Error())
 
@@ -151,29 +484,74 @@ func (c *Client) doHandshake(conn Conn, metrics *Metrics) (ck CipherKey, err err
 		return
 	}
 
-	var cck CryptCipherKey
-	err = p.GetGob(&cck)
+	if p.Type == Error {
+		re := &RejectedError{}
+		err = conn.getPayload(p, re)
+		if err == nil {
+			err = re
+		}
+
+		c.logger.Error(err.Error())
+
+		return
+	}
+
+	var welcome Welcome
+	err = conn.getPayload(p, &welcome)
+	if err != nil {
+		var cck CryptCipherKey
+		err = conn.getPayload(p, &cck)
+		if err != nil {
+			c.logger.Error(err.Error())
+
+			return
+		}
+
+		welcome = Welcome{
+			Key:         cck,
+			Version:     LegacyProtocolVersion,
+			CipherSuite: CipherSuiteAES128GCM,
+		}
+	}
+
+	var ck CipherKey
+	ck, err = c.rsa.PrivateKey().Decode(welcome.Key)
 	if err != nil {
 		c.logger.Error(err.Error())
 
 		return
 	}
 
-	ck, err = c.rsa.PrivateKey().Decode(cck)
+	sk, err = newSessionKey(ck)
 	if err != nil {
 		c.logger.Error(err.Error())
 
 		return
 	}
 
+	session = Session{
+		Version:      welcome.Version,
+		CipherSuite:  welcome.CipherSuite,
+		Capabilities: welcome.Capabilities,
+	}
+
+	conn.setLegacy(session.Capabilities)
+
 	metrics.fixHandshake()
 
 	return
 }
 
-func (c *Client) doExchange(conn Conn, metrics *Metrics, in Message) (out Message, err error) {
+func (c *Client) doExchange(conn *Conn, metrics *Metrics, in Message) (out Message, err error) {
+	err = in.compress(c.tcp.session.compression(c.settings.Compression))
+	if err != nil {
+		c.logger.Error(err.Error())
+
+		return
+	}
+
 	var cm CryptMessage
-	cm, err = in.Encode(*c.tcp.cipherKey)
+	cm, err = conn.encodeMessage(in, c.tcp.cipherKey)
 	if err != nil {
 		c.logger.Error(err.Error())
 
@@ -183,7 +561,7 @@ func (c *Client) doExchange(conn Conn, metrics *Metrics, in Message) (out Messag
 	p := Package{
 		Type: Exchange,
 	}
-	err = p.SetGob(cm)
+	err = conn.setPayload(&p, cm)
 	if err != nil {
 		c.logger.Error(err.Error())
 
@@ -207,19 +585,28 @@ func (c *Client) doExchange(conn Conn, metrics *Metrics, in Message) (out Messag
 	}
 
 	if p.Type == Error {
-		_ = p.GetGob(&err)
+		err = conn.packageError(p)
+
+		c.logger.Error(err.Error())
 
 		return
 	}
 
-	err = p.GetGob(&cm)
+	err = conn.getPayload(p, &cm)
 	if err != nil {
 		c.logger.Error(err.Error())
 
 		return
 	}
 
-	out, err = cm.Decode(*c.tcp.cipherKey)
+	out, err = conn.decodeMessage(cm, c.tcp.cipherKey)
+	if err != nil {
+		c.logger.Error(err.Error())
+
+		return
+	}
+
+	err = out.decompress(c.settings.decompression)
 	if err != nil {
 		c.logger.Error(err.Error())
 
@@ -229,7 +616,7 @@ func (c *Client) doExchange(conn Conn, metrics *Metrics, in Message) (out Messag
 	metrics.fixReadDuration()
 
 	if out.Error != nil {
-		err = out.Error
+		err = out.remoteError()
 
 		c.logger.Error(err.Error())
 
@@ -238,3 +625,63 @@ func (c *Client) doExchange(conn Conn, metrics *Metrics, in Message) (out Messag
 
 	return
 }
+
+func (c *Client) doLink(conn *Conn) (peer *Peer, err error) {
+	var caps Data
+	err = caps.Encode(conn.contentCodec(), localCapabilities())
+	if err != nil {
+		c.logger.Error(err.Error())
+
+		return
+	}
+
+	var msg Message
+	msg.setData(caps)
+
+	var cm CryptMessage
+	cm, err = conn.encodeMessage(msg, c.tcp.cipherKey)
+	if err != nil {
+		c.logger.Error(err.Error())
+
+		return
+	}
+
+	p := Package{
+		Type: Link,
+	}
+	err = conn.setPayload(&p, cm)
+	if err != nil {
+		c.logger.Error(err.Error())
+
+		return
+	}
+
+	err = conn.WritePackage(p)
+	if err != nil {
+		c.logger.Error(err.Error())
+
+		return
+	}
+
+	conn.stream()
+
+	compression := c.tcp.session.compression(c.settings.Compression)
+	peer = newPeer(conn, c.tcp.cipherKey, c.logger, context.Background(), compression, c.router)
+
+	err = peer.confirm()
+	if err != nil {
+		c.logger.Error(err.Error())
+
+		return
+	}
+
+	conn.link()
+
+	return
+}
+
+func keepsSession(err error) (ok bool) {
+	return errors.Is(err, Overloaded) ||
+		errors.Is(err, RateLimited) ||
+		errors.Is(err, DecompressionLimitError)
+}
@@ -4,33 +4,100 @@ import "time"
 
 type ClientSettings struct {
 	Limiter
+	Compression
 	Retry
+	Transport
+	RateLimits
+	Logging
 }
 
 func NewClientSettings() (stg *ClientSettings) {
 	return &ClientSettings{
 		Limiter: Limiter{
-			Timeout: Timeout{
-				conn: DefaultConnTimeout,
-			},
-			body: DefaultBodyLimit,
+			Timeout:       newTimeout(),
+			body:          DefaultBodyLimit,
+			decompression: DefaultDecompressionLimit,
 		},
 		Retry: Retry{
 			retries: DefaultRetries,
 			delay:   DefaultDelayTimeout,
 		},
+		Transport: Transport{
+			wire: GobWireName,
+		},
+		Logging: Logging{
+			sampling: DefaultAccessLogSampling,
+			level:    DefaultLogLevel,
+		},
 	}
 }
 
+// SetConnTimeout sets timeouts of handshake, read, write and idle.
 func (stg *ClientSettings) SetConnTimeout(dur time.Duration) {
-	stg.Limiter.conn = dur
+	stg.Limiter.setConn(dur)
+}
+
+func (stg *ClientSettings) SetHandshakeTimeout(dur time.Duration) {
+	stg.Limiter.handshake = dur
+}
+
+// SetReadTimeout limits reading of a package after its first byte.
+func (stg *ClientSettings) SetReadTimeout(dur time.Duration) {
+	stg.Limiter.read = dur
+}
+
+func (stg *ClientSettings) SetWriteTimeout(dur time.Duration) {
+	stg.Limiter.write = dur
+}
+
+// SetIdleTimeout limits waiting for the next package, links wait for packages without it.
+func (stg *ClientSettings) SetIdleTimeout(dur time.Duration) {
+	stg.Limiter.idle = dur
+}
+
+func (stg *ClientSettings) SetHandleTimeout(dur time.Duration) {
+	stg.Limiter.handle = dur
 }
 
 func (stg *ClientSettings) SetBodyLimit(limit uint) {
 	stg.Limiter.body = int(limit)
 }
 
+// SetDecompressionLimit limits a size of decompressed content, zero turns the limit off.
+func (stg *ClientSettings) SetDecompressionLimit(limit uint) {
+	stg.Limiter.decompression = int(limit)
+}
+
 func (stg *ClientSettings) SetRetry(retries uint, delay time.Duration) {
 	stg.Retry.retries = retries
 	stg.Retry.delay = delay
 }
+
+func (stg *ClientSettings) SetCompression(name string, threshold uint) {
+	stg.Compression.name = name
+	stg.Compression.threshold = int(threshold)
+}
+
+func (stg *ClientSettings) SetWireCodec(name string) {
+	stg.Transport.wire = name
+}
+
+// SetRateLimit makes the client wait before requests to send not more than the limit of requests per second.
+func (stg *ClientSettings) SetRateLimit(limit float64, burst uint) {
+	stg.RateLimits.requests = newRateLimit(limit, burst)
+}
+
+// SetTopicRateLimit makes the client wait before requests of the topic to send not more than the limit of them per second.
+func (stg *ClientSettings) SetTopicRateLimit(topic string, limit float64, burst uint) {
+	stg.RateLimits.setTopic(topic, newRateLimit(limit, burst))
+}
+
+// SetAccessLogSampling sets a share of requests that are logged: 1 logs all requests, 0 turns the access log off.
+func (stg *ClientSettings) SetAccessLogSampling(rate float64) {
+	stg.Logging.sampling = rate
+}
+
+// SetLogLevel sets a minimum level of client records, it's applied by client.SetSettings.
+func (stg *ClientSettings) SetLogLevel(level Level) {
+	stg.Logging.level = level
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+type options struct {
+	input  string
+	output string
+	types  string
+	codec  string
+	tests  bool
+}
+
+type service struct {
+	Name    string
+	Methods []method
+}
+
+type method struct {
+	Name     string
+	Req      string
+	ReqIsPtr bool
+	Res      string
+	ResIsPtr bool
+}
+
+type file struct {
+	Package  string
+	Codec    string
+	Imports  []string
+	Services []service
+}
+
+func generate(opts options, src []byte) (code, test []byte, err error) {
+	fset := token.NewFileSet()
+
+	var f *ast.File
+	f, err = parser.ParseFile(fset, opts.input, src, parser.SkipObjectResolution)
+	if err != nil {
+		return
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range strings.Split(opts.types, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			wanted[name] = false
+		}
+	}
+
+	out := file{
+		Package: f.Name.Name,
+		Codec:   opts.codec,
+	}
+
+	used := map[string]bool{}
+
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+
+			it, ok := ts.Type.(*ast.InterfaceType)
+			if !ok {
+				continue
+			}
+
+			_, ok = wanted[ts.Name.Name]
+			if !ok && len(wanted) > 0 {
+				continue
+			}
+
+			var svc service
+			svc, err = parseService(fset, ts.Name.Name, it, used)
+			if err != nil {
+				return
+			}
+
+			wanted[ts.Name.Name] = true
+			out.Services = append(out.Services, svc)
+		}
+	}
+
+	for name, found := range wanted {
+		if !found {
+			err = fmt.Errorf("interface %s is not found", name)
+
+			return
+		}
+	}
+
+	if len(out.Services) == 0 {
+		err = fmt.Errorf("no interfaces are found in %s", opts.input)
+
+		return
+	}
+
+	out.Imports = imports(f, used)
+
+	code, err = render(codeTemplate, out)
+	if err != nil || !opts.tests {
+		return
+	}
+
+	test, err = render(testTemplate, out)
+
+	return
+}
+
+func parseService(fset *token.FileSet, name string, it *ast.InterfaceType, used map[string]bool) (svc service, err error) {
+	svc.Name = name
+
+	for _, field := range it.Methods.List {
+		ft, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) != 1 {
+			err = fmt.Errorf("%s: embedded interfaces are not supported", fset.Position(field.Pos()))
+
+			return
+		}
+
+		params := fieldTypes(ft.Params)
+		results := fieldTypes(ft.Results)
+
+		if len(params) != 2 || types.ExprString(params[0]) != "context.Context" ||
+			len(results) != 2 || types.ExprString(results[1]) != "error" {
+			err = fmt.Errorf("%s: method %s.%s must be func(context.Context, Req) (Res, error)",
+				fset.Position(field.Pos()), name, field.Names[0].Name)
+
+			return
+		}
+
+		collectPackages(params[1], used)
+		collectPackages(results[0], used)
+
+		_, reqIsPtr := params[1].(*ast.StarExpr)
+		_, resIsPtr := results[0].(*ast.StarExpr)
+
+		svc.Methods = append(svc.Methods, method{
+			Name:     field.Names[0].Name,
+			Req:      types.ExprString(params[1]),
+			ReqIsPtr: reqIsPtr,
+			Res:      types.ExprString(results[0]),
+			ResIsPtr: resIsPtr,
+		})
+	}
+
+	return
+}
+
+func fieldTypes(list *ast.FieldList) (exprs []ast.Expr) {
+	if list == nil {
+		return
+	}
+
+	for _, field := range list.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+
+		for i := 0; i < n; i++ {
+			exprs = append(exprs, field.Type)
+		}
+	}
+
+	return
+}
+
+func collectPackages(expr ast.Expr, used map[string]bool) {
+	ast.Inspect(expr, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+
+		ident, ok := sel.X.(*ast.Ident)
+		if ok {
+			used[ident.Name] = true
+		}
+
+		return false
+	})
+}
+
+func imports(f *ast.File, used map[string]bool) (specs []string) {
+	for _, spec := range f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+
+		name := path.Base(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+
+		if !used[name] {
+			continue
+		}
+
+		if spec.Name != nil {
+			specs = append(specs, spec.Name.Name+" "+spec.Path.Value)
+		} else {
+			specs = append(specs, spec.Path.Value)
+		}
+	}
+
+	sort.Strings(specs)
+
+	return
+}
+
+func render(tmpl *template.Template, f file) (bs []byte, err error) {
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, f)
+	if err != nil {
+		return
+	}
+
+	bs, err = format.Source(buf.Bytes())
+
+	return
+}
+
+var codeTemplate = template.Must(template.New("code").Parse(`// Code generated by p2pgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+
+	p2p "github.com/leprosus/golang-p2p"
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+)
+{{range $svc := .Services}}
+const (
+{{- range .Methods}}
+	{{$svc.Name}}{{.Name}}Topic = "{{$svc.Name}}.{{.Name}}"
+{{- end}}
+)
+
+type {{.Name}}Client struct {
+	sender p2p.Sender
+	codec  string
+}
+
+var _ {{.Name}} = (*{{.Name}}Client)(nil)
+
+func New{{.Name}}Client(sender p2p.Sender) (c *{{.Name}}Client) {
+	return &{{.Name}}Client{
+		sender: sender,
+		codec:  "{{$.Codec}}",
+	}
+}
+
+func (c *{{.Name}}Client) SetCodec(codec string) {
+	c.codec = codec
+}
+{{range .Methods}}
+func (c *{{$svc.Name}}Client) {{.Name}}(ctx context.Context, req {{.Req}}) (res {{.Res}}, err error) {
+	return p2p.CallCodecContext[{{.Req}}, {{.Res}}](ctx, c.sender, c.codec, {{$svc.Name}}{{.Name}}Topic, req)
+}
+{{end}}
+func Register{{.Name}}Server(setter p2p.HandlerSetter, impl {{.Name}}) {
+{{- range .Methods}}
+	p2p.HandleTyped(setter, {{$svc.Name}}{{.Name}}Topic, impl.{{.Name}})
+{{- end}}
+}
+{{end}}`))
+
+var testTemplate = template.Must(template.New("test").Parse(`// Code generated by p2pgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+	"testing"
+
+	p2p "github.com/leprosus/golang-p2p"
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+)
+
+type p2pgenLoopback map[string]p2p.Handler
+
+func (l p2pgenLoopback) SetHandler(topic string, handler p2p.Handler) {
+	l[topic] = handler
+}
+
+func (l p2pgenLoopback) Send(topic string, req p2p.Data) (res p2p.Data, err error) {
+	handler, ok := l[topic]
+	if !ok {
+		err = p2p.UnsupportedTopic
+
+		return
+	}
+
+	return handler(context.Background(), req)
+}
+{{range $svc := .Services}}
+type p2pgen{{.Name}} struct{}
+{{range .Methods}}
+func (p2pgen{{$svc.Name}}) {{.Name}}(_ context.Context, _ {{.Req}}) (res {{.Res}}, err error) {
+{{- if .ResIsPtr}}
+	res = new({{slice .Res 1}})
+{{end}}
+	return
+}
+{{end}}
+func Test{{.Name}}P2P(t *testing.T) {
+	loopback := p2pgenLoopback{}
+	Register{{.Name}}Server(loopback, p2pgen{{.Name}}{})
+
+	client := New{{.Name}}Client(loopback)
+
+	var err error
+{{- range .Methods}}
+{{if .ReqIsPtr}}
+	req{{.Name}} := new({{slice .Req 1}})
+{{- else}}
+	var req{{.Name}} {{.Req}}
+{{- end}}
+	_, err = client.{{.Name}}(context.Background(), req{{.Name}})
+	if err != nil {
+		t.Fatalf("{{$svc.Name}}.{{.Name}}: %v", err)
+	}
+{{- end}}
+}
+{{end}}`))
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const greeterSource = `package greeter
+
+import (
+	"context"
+	"time"
+)
+
+type HelloRequest struct {
+	Name string
+	At   time.Time
+}
+
+type HelloResponse struct {
+	Text string
+}
+
+type Greeter interface {
+	Hello(ctx context.Context, req HelloRequest) (res HelloResponse, err error)
+	Bye(ctx context.Context, req *HelloRequest) (*HelloResponse, error)
+}
+
+type Ignored interface {
+	Wrong(req HelloRequest) error
+}
+`
+
+func TestGenerate(t *testing.T) {
+	code, test, err := generate(options{
+		input: "greeter.go",
+		types: "Greeter",
+		codec: "json",
+		tests: true,
+	}, []byte(greeterSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`GreeterHelloTopic = "Greeter.Hello"`,
+		`codec:  "json"`,
+		`p2p.CallCodecContext[*HelloRequest, *HelloResponse](ctx, c.sender, c.codec, GreeterByeTopic, req)`,
+		`p2p.HandleTyped(setter, GreeterHelloTopic, impl.Hello)`,
+	} {
+		if !strings.Contains(string(code), want) {
+			t.Fatalf("generated code does not contain %q:\n%s", want, code)
+		}
+	}
+
+	if strings.Contains(string(code), `"time"`) {
+		t.Fatalf("generated code contains unused import:\n%s", code)
+	}
+
+	for _, want := range []string{
+		"func TestGreeterP2P(t *testing.T)",
+		"reqBye := new(HelloRequest)",
+		"res = new(HelloResponse)",
+	} {
+		if !strings.Contains(string(test), want) {
+			t.Fatalf("generated test does not contain %q:\n%s", want, test)
+		}
+	}
+}
+
+func TestGenerateInvalidMethod(t *testing.T) {
+	_, _, err := generate(options{
+		input: "greeter.go",
+		types: "Ignored",
+	}, []byte(greeterSource))
+	if err == nil || !strings.Contains(err.Error(), "Ignored.Wrong") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestGenerateUnknownType(t *testing.T) {
+	_, _, err := generate(options{
+		input: "greeter.go",
+		types: "Unknown",
+	}, []byte(greeterSource))
+	if err == nil || !strings.Contains(err.Error(), "Unknown") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
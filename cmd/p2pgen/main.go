@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	var opts options
+
+	flag.StringVar(&opts.types, "type", "", "comma-separated list of interface names (all interfaces by default)")
+	flag.StringVar(&opts.codec, "codec", "gob", "codec name that the generated client uses by default")
+	flag.StringVar(&opts.output, "output", "", "output file name (<file>_p2p.go by default)")
+	flag.BoolVar(&opts.tests, "tests", true, "generate tests for the generated client and server adapter")
+	flag.Usage = func() {
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "Usage: p2pgen [flags] file.go")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts.input = flag.Arg(0)
+	if opts.output == "" {
+		opts.output = strings.TrimSuffix(opts.input, ".go") + "_p2p.go"
+	}
+
+	err := run(opts)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "p2pgen:", err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(opts options) (err error) {
+	var src []byte
+	src, err = os.ReadFile(opts.input)
+	if err != nil {
+		return
+	}
+
+	var code, test []byte
+	code, test, err = generate(opts, src)
+	if err != nil {
+		return
+	}
+
+	err = os.WriteFile(opts.output, code, 0644)
+	if err != nil {
+		return
+	}
+
+	if test != nil {
+		err = os.WriteFile(strings.TrimSuffix(opts.output, ".go")+"_test.go", test, 0644)
+	}
+
+	return
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	p2p "github.com/leprosus/golang-p2p"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string)  {}
+func (nopLogger) Warn(string)  {}
+func (nopLogger) Error(string) {}
+
+func main() {
+	host := flag.String("host", "localhost", "server host")
+	port := flag.String("port", "8080", "server port")
+	asJson := flag.Bool("json", false, "prints raw JSON")
+	flag.Parse()
+
+	client, err := p2p.NewClient(p2p.NewTCP(*host, *port))
+	if err != nil {
+		exit(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	var info p2p.ServerInfo
+	info, err = client.Reflect()
+	if err != nil {
+		exit(err)
+	}
+
+	if *asJson {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(info)
+	} else {
+		err = printInfo(os.Stdout, info)
+	}
+
+	if err != nil {
+		exit(err)
+	}
+}
+
+func printInfo(w io.Writer, info p2p.ServerInfo) (err error) {
+	version := info.Version
+	if version == "" {
+		version = "unknown"
+	}
+
+	lines := []string{
+		fmt.Sprintf("version: %s", version),
+		fmt.Sprintf("protocol: %d", info.Protocol),
+		fmt.Sprintf("cipher suites: %s", strings.Join(info.CipherSuites, ", ")),
+		fmt.Sprintf("capabilities: %s", strings.Join(info.Capabilities, ", ")),
+		fmt.Sprintf("codecs: %s", strings.Join(info.Codecs, ", ")),
+		fmt.Sprintf("compressors: %s", strings.Join(info.Compressors, ", ")),
+		"topics:",
+	}
+
+	for _, topic := range info.Topics {
+		if topic.Schema == nil {
+			lines = append(lines, fmt.Sprintf("  %s", topic.Topic))
+
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("  %s(%s) %s", topic.Topic,
+			topic.Schema.Request.Name, topic.Schema.Response.Name))
+		lines = append(lines, fields("request", topic.Schema.Request)...)
+		lines = append(lines, fields("response", topic.Schema.Response)...)
+	}
+
+	_, err = fmt.Fprintln(w, strings.Join(lines, "\n"))
+
+	return
+}
+
+func fields(title string, ts p2p.TypeSchema) (lines []string) {
+	for _, field := range ts.Fields {
+		lines = append(lines, fmt.Sprintf("    %s.%s %s", title, field.Name, field.Type))
+	}
+
+	return
+}
+
+func exit(err error) {
+	_, _ = fmt.Fprintln(os.Stderr, "p2preflect:", err.Error())
+	os.Exit(1)
+}
@@ -0,0 +1,110 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+type Codec interface {
+	Name() (name string)
+	Marshal(val interface{}) (bs []byte, err error)
+	Unmarshal(bs []byte, val interface{}) (err error)
+}
+
+const (
+	GobCodecName  = "gob"
+	JsonCodecName = "json"
+)
+
+var codecs = struct {
+	mx  sync.RWMutex
+	set map[string]Codec
+}{
+	set: map[string]Codec{},
+}
+
+func init() {
+	RegisterCodec(gobCodec{})
+	RegisterCodec(jsonCodec{})
+}
+
+func RegisterCodec(codec Codec) {
+	codecs.mx.Lock()
+	codecs.set[codec.Name()] = codec
+	codecs.mx.Unlock()
+}
+
+func GetCodec(name string) (codec Codec, ok bool) {
+	codecs.mx.RLock()
+	codec, ok = codecs.set[name]
+	codecs.mx.RUnlock()
+
+	return
+}
+
+func Codecs() (names []string) {
+	codecs.mx.RLock()
+	for name := range codecs.set {
+		names = append(names, name)
+	}
+	codecs.mx.RUnlock()
+
+	sort.Strings(names)
+
+	return
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Name() (name string) {
+	return GobCodecName
+}
+
+func (gobCodec) Marshal(val interface{}) (bs []byte, err error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	err = gob.NewEncoder(buf).Encode(val)
+	if err != nil {
+		return
+	}
+
+	bs = append([]byte(nil), buf.Bytes()...)
+
+	return
+}
+
+func (gobCodec) Unmarshal(bs []byte, val interface{}) (err error) {
+	err = gob.NewDecoder(bytes.NewReader(bs)).Decode(val)
+
+	return
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() (name string) {
+	return JsonCodecName
+}
+
+func (jsonCodec) Marshal(val interface{}) (bs []byte, err error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	err = json.NewEncoder(buf).Encode(val)
+	if err != nil {
+		return
+	}
+
+	bs = append([]byte(nil), buf.Bytes()...)
+
+	return
+}
+
+func (jsonCodec) Unmarshal(bs []byte, val interface{}) (err error) {
+	err = json.NewDecoder(bytes.NewReader(bs)).Decode(val)
+
+	return
+}
@@ -0,0 +1,30 @@
+package cbor
+
+import (
+	"github.com/fxamacker/cbor/v2"
+	p2p "github.com/leprosus/golang-p2p"
+)
+
+const Name = "cbor"
+
+type Codec struct{}
+
+func init() {
+	p2p.RegisterCodec(Codec{})
+}
+
+func (Codec) Name() (name string) {
+	return Name
+}
+
+func (Codec) Marshal(val interface{}) (bs []byte, err error) {
+	bs, err = cbor.Marshal(val)
+
+	return
+}
+
+func (Codec) Unmarshal(bs []byte, val interface{}) (err error) {
+	err = cbor.Unmarshal(bs, val)
+
+	return
+}
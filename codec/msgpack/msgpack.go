@@ -0,0 +1,30 @@
+package msgpack
+
+import (
+	p2p "github.com/leprosus/golang-p2p"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const Name = "msgpack"
+
+type Codec struct{}
+
+func init() {
+	p2p.RegisterCodec(Codec{})
+}
+
+func (Codec) Name() (name string) {
+	return Name
+}
+
+func (Codec) Marshal(val interface{}) (bs []byte, err error) {
+	bs, err = msgpack.Marshal(val)
+
+	return
+}
+
+func (Codec) Unmarshal(bs []byte, val interface{}) (err error) {
+	err = msgpack.Unmarshal(bs, val)
+
+	return
+}
@@ -0,0 +1,48 @@
+package protobuf
+
+import (
+	"errors"
+
+	p2p "github.com/leprosus/golang-p2p"
+	"google.golang.org/protobuf/proto"
+)
+
+const Name = "protobuf"
+
+var NotProtoMessage = errors.New("value is not a proto.Message")
+
+type Codec struct{}
+
+func init() {
+	p2p.RegisterCodec(Codec{})
+}
+
+func (Codec) Name() (name string) {
+	return Name
+}
+
+func (Codec) Marshal(val interface{}) (bs []byte, err error) {
+	msg, ok := val.(proto.Message)
+	if !ok {
+		err = NotProtoMessage
+
+		return
+	}
+
+	bs, err = proto.Marshal(msg)
+
+	return
+}
+
+func (Codec) Unmarshal(bs []byte, val interface{}) (err error) {
+	msg, ok := val.(proto.Message)
+	if !ok {
+		err = NotProtoMessage
+
+		return
+	}
+
+	err = proto.Unmarshal(bs, msg)
+
+	return
+}
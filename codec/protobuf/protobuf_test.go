@@ -0,0 +1,35 @@
+package protobuf
+
+import (
+	"errors"
+	"testing"
+
+	p2p "github.com/leprosus/golang-p2p"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCodec(t *testing.T) {
+	var d p2p.Data
+	err := d.Encode(Name, wrapperspb.String("some very important text"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val := &wrapperspb.StringValue{}
+	err = d.Decode(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if val.GetValue() != "some very important text" {
+		t.Fatalf("unexpected value %q", val.GetValue())
+	}
+}
+
+func TestCodecNotProtoMessage(t *testing.T) {
+	var d p2p.Data
+	err := d.Encode(Name, "text")
+	if !errors.Is(err, NotProtoMessage) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
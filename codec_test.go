@@ -0,0 +1,48 @@
+package p2p
+
+import (
+	"errors"
+	"testing"
+)
+
+type codecValue struct {
+	Text string
+}
+
+func TestDataDecode(t *testing.T) {
+	for _, name := range []string{GobCodecName, JsonCodecName} {
+		var d Data
+		err := d.Encode(name, codecValue{Text: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d.ContentType != name {
+			t.Fatalf("unexpected content type %q", d.ContentType)
+		}
+
+		var val codecValue
+		err = d.Decode(&val)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if val.Text != name {
+			t.Fatalf("unexpected value %q", val.Text)
+		}
+	}
+}
+
+func TestDataUnsupportedCodec(t *testing.T) {
+	var d Data
+	err := d.Encode("unknown", codecValue{})
+	if !errors.Is(err, UnsupportedCodec) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	d.SetBytes([]byte("raw"))
+	err = d.Decode(&codecValue{})
+	if !errors.Is(err, UnsupportedCodec) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
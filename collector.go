@@ -0,0 +1,229 @@
+package p2p
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type MetricType string
+
+const (
+	CounterMetric   MetricType = "counter"
+	GaugeMetric     MetricType = "gauge"
+	HistogramMetric MetricType = "histogram"
+)
+
+type Collector interface {
+	Collect() (families []MetricFamily)
+}
+
+type MetricFamily struct {
+	Name    string
+	Help    string
+	Type    MetricType
+	Metrics []Metric
+}
+
+type Metric struct {
+	Labels    map[string]string
+	Value     float64
+	Histogram *Histogram
+}
+
+type Histogram struct {
+	Buckets []Bucket
+	Count   uint64
+	Sum     float64
+}
+
+// Bucket counts observations that are less than or equal to the upper bound,
+// so counts of buckets are cumulative.
+type Bucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+var DefaultBuckets = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5}
+
+func newHistogram(buckets []float64) (h *Histogram) {
+	h = &Histogram{
+		Buckets: make([]Bucket, len(buckets)),
+	}
+
+	for i, bound := range buckets {
+		h.Buckets[i].UpperBound = bound
+	}
+
+	return
+}
+
+func (h *Histogram) observe(value float64) {
+	for i := range h.Buckets {
+		if value <= h.Buckets[i].UpperBound {
+			h.Buckets[i].Count++
+		}
+	}
+
+	h.Count++
+	h.Sum += value
+}
+
+func (h *Histogram) copy() (c *Histogram) {
+	c = &Histogram{
+		Buckets: append([]Bucket(nil), h.Buckets...),
+		Count:   h.Count,
+		Sum:     h.Sum,
+	}
+
+	return
+}
+
+type Registry struct {
+	mx         sync.RWMutex
+	collectors []Collector
+}
+
+func NewRegistry() (r *Registry) {
+	return &Registry{
+		mx: sync.RWMutex{},
+	}
+}
+
+func (r *Registry) Register(collector Collector) {
+	r.mx.Lock()
+	r.collectors = append(r.collectors, collector)
+	r.mx.Unlock()
+}
+
+// Collect merges families with the same name from all registered collectors.
+func (r *Registry) Collect() (families []MetricFamily) {
+	r.mx.RLock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mx.RUnlock()
+
+	index := map[string]int{}
+	for _, collector := range collectors {
+		for _, family := range collector.Collect() {
+			i, ok := index[family.Name]
+			if !ok {
+				index[family.Name] = len(families)
+				families = append(families, family)
+
+				continue
+			}
+
+			families[i].Metrics = append(families[i].Metrics, family.Metrics...)
+		}
+	}
+
+	sort.SliceStable(families, func(i, j int) bool {
+		return families[i].Name < families[j].Name
+	})
+
+	return
+}
+
+func (r *Registry) Handler() (handler http.Handler) {
+	return MetricsHandler(r)
+}
+
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+func MetricsHandler(collector Collector) (handler http.Handler) {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", PrometheusContentType)
+
+		_ = WritePrometheus(w, collector.Collect())
+	})
+}
+
+func WritePrometheus(w io.Writer, families []MetricFamily) (err error) {
+	bw := bufio.NewWriter(w)
+
+	for _, family := range families {
+		if family.Help != "" {
+			_, _ = fmt.Fprintf(bw, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+		}
+
+		_, _ = fmt.Fprintf(bw, "# TYPE %s %s\n", family.Name, family.Type)
+
+		for _, metric := range family.Metrics {
+			if family.Type != HistogramMetric || metric.Histogram == nil {
+				writeSample(bw, family.Name, metric.Labels, "", "", metric.Value)
+
+				continue
+			}
+
+			h := metric.Histogram
+			for _, bucket := range h.Buckets {
+				writeSample(bw, family.Name+"_bucket", metric.Labels, "le", formatValue(bucket.UpperBound), float64(bucket.Count))
+			}
+
+			writeSample(bw, family.Name+"_bucket", metric.Labels, "le", "+Inf", float64(h.Count))
+			writeSample(bw, family.Name+"_sum", metric.Labels, "", "", h.Sum)
+			writeSample(bw, family.Name+"_count", metric.Labels, "", "", float64(h.Count))
+		}
+	}
+
+	err = bw.Flush()
+
+	return
+}
+
+func writeSample(w *bufio.Writer, name string, labels map[string]string, extraName, extraValue string, value float64) {
+	names := make([]string, 0, len(labels))
+	for label := range labels {
+		names = append(names, label)
+	}
+
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names)+1)
+	for _, label := range names {
+		pairs = append(pairs, label+`="`+escapeLabel(labels[label])+`"`)
+	}
+
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+extraValue+`"`)
+	}
+
+	_, _ = w.WriteString(name)
+	if len(pairs) > 0 {
+		_, _ = w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+
+	_, _ = w.WriteString(" " + formatValue(value) + "\n")
+}
+
+func formatValue(value float64) (str string) {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(str string) (escaped string) {
+	return helpReplacer.Replace(str)
+}
+
+func escapeLabel(str string) (escaped string) {
+	return labelReplacer.Replace(str)
+}
@@ -0,0 +1,129 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	h := newHistogram([]float64{0.1, 1})
+	h.observe(0.05)
+	h.observe(0.5)
+	h.observe(2)
+
+	families := []MetricFamily{
+		{
+			Name: "requests_total",
+			Help: "Number of requests.",
+			Type: CounterMetric,
+			Metrics: []Metric{{
+				Labels: map[string]string{"topic": `a"b`, "role": "server"},
+				Value:  3,
+			}},
+		},
+		{
+			Name: "duration_seconds",
+			Type: HistogramMetric,
+			Metrics: []Metric{{
+				Histogram: h,
+			}},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := WritePrometheus(&buf, families)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP requests_total Number of requests.
+# TYPE requests_total counter
+requests_total{role="server",topic="a\"b"} 3
+# TYPE duration_seconds histogram
+duration_seconds_bucket{le="0.1"} 1
+duration_seconds_bucket{le="1"} 2
+duration_seconds_bucket{le="+Inf"} 3
+duration_seconds_sum 2.55
+duration_seconds_count 3
+`
+
+	if buf.String() != expected {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestCollector(t *testing.T) {
+	port := newTestPort(t)
+
+	server, err := NewServer(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	server.SetHandler("user.{id}", func(ctx context.Context, req Data) (res Data, err error) {
+		return
+	})
+
+	server.SetHandler("fail", func(ctx context.Context, req Data) (res Data, err error) {
+		err = errors.New("failed")
+
+		return
+	})
+
+	startTestServer(t, server)
+
+	client, err := NewClient(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	settings := NewClientSettings()
+	settings.SetRetry(1, 0)
+	client.SetSettings(settings)
+
+	for _, topic := range []string{"user.1", "user.2", "fail", "unknown"} {
+		_, _ = client.Send(topic, Data{})
+	}
+
+	registry := NewRegistry()
+	registry.Register(server.Collector())
+	registry.Register(client.Collector())
+
+	rec := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if rec.Header().Get("Content-Type") != PrometheusContentType {
+		t.Fatalf("unexpected content type %s", rec.Header().Get("Content-Type"))
+	}
+
+	bs, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := string(bs)
+	for _, line := range []string{
+		`p2p_requests_total{role="server",topic="user.{id}"} 2`,
+		`p2p_requests_total{role="server",topic="unsupported"} 1`,
+		`p2p_request_errors_total{role="server",topic="fail"} 1`,
+		`p2p_requests_total{role="client",topic="user.1"} 1`,
+		`p2p_request_errors_total{role="client",topic="unknown"} 1`,
+		`p2p_requests_in_flight{role="client"} 0`,
+		`p2p_phase_duration_seconds_count{phase="handle",role="server",topic="user.{id}"} 2`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Fatalf("%s is not found in:\n%s", line, out)
+		}
+	}
+
+	if strings.Count(out, "# TYPE p2p_requests_total") != 1 {
+		t.Fatalf("families are not merged:\n%s", out)
+	}
+}
@@ -0,0 +1,28 @@
+package snappy
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/s2"
+	p2p "github.com/leprosus/golang-p2p"
+)
+
+const Name = "snappy"
+
+type Compressor struct{}
+
+func init() {
+	p2p.RegisterCompressor(Compressor{})
+}
+
+func (Compressor) Name() (name string) {
+	return Name
+}
+
+func (Compressor) NewWriter(w io.Writer) (wc io.WriteCloser, err error) {
+	return s2.NewWriter(w, s2.WriterSnappyCompat()), nil
+}
+
+func (Compressor) NewReader(r io.Reader) (rc io.ReadCloser, err error) {
+	return io.NopCloser(s2.NewReader(r)), nil
+}
@@ -0,0 +1,38 @@
+package zstd
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	p2p "github.com/leprosus/golang-p2p"
+)
+
+const Name = "zstd"
+
+type Compressor struct{}
+
+func init() {
+	p2p.RegisterCompressor(Compressor{})
+}
+
+func (Compressor) Name() (name string) {
+	return Name
+}
+
+func (Compressor) NewWriter(w io.Writer) (wc io.WriteCloser, err error) {
+	wc, err = zstd.NewWriter(w)
+
+	return
+}
+
+func (Compressor) NewReader(r io.Reader) (rc io.ReadCloser, err error) {
+	var dec *zstd.Decoder
+	dec, err = zstd.NewReader(r)
+	if err != nil {
+		return
+	}
+
+	rc = dec.IOReadCloser()
+
+	return
+}
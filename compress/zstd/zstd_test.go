@@ -0,0 +1,44 @@
+package zstd
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestCompressor(t *testing.T) {
+	origin := bytes.Repeat([]byte("some very important text "), 100)
+
+	var buf bytes.Buffer
+	wc, err := Compressor{}.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = wc.Write(origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = wc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := Compressor{}.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	decompressed, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(origin, decompressed) {
+		t.Fatal("Origin and Decompressed are not equal")
+	}
+}
@@ -0,0 +1,161 @@
+package p2p
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sort"
+	"sync"
+)
+
+type Compressor interface {
+	Name() (name string)
+	NewWriter(w io.Writer) (wc io.WriteCloser, err error)
+	NewReader(r io.Reader) (rc io.ReadCloser, err error)
+}
+
+const GzipCompressorName = "gzip"
+
+var compressors = struct {
+	mx  sync.RWMutex
+	set map[string]Compressor
+}{
+	set: map[string]Compressor{},
+}
+
+func init() {
+	RegisterCompressor(gzipCompressor{})
+}
+
+func RegisterCompressor(compressor Compressor) {
+	compressors.mx.Lock()
+	compressors.set[compressor.Name()] = compressor
+	compressors.mx.Unlock()
+}
+
+func GetCompressor(name string) (compressor Compressor, ok bool) {
+	compressors.mx.RLock()
+	compressor, ok = compressors.set[name]
+	compressors.mx.RUnlock()
+
+	return
+}
+
+func Compressors() (names []string) {
+	compressors.mx.RLock()
+	for name := range compressors.set {
+		names = append(names, name)
+	}
+	compressors.mx.RUnlock()
+
+	sort.Strings(names)
+
+	return
+}
+
+func (cmp Compression) reply(name string) (c Compression) {
+	c = cmp
+	if name != "" {
+		c.name = name
+	}
+
+	return
+}
+
+func (msg *Message) compress(cmp Compression) (err error) {
+	if cmp.name == "" || len(msg.Content) < cmp.threshold {
+		return
+	}
+
+	compressor, ok := GetCompressor(cmp.name)
+	if !ok {
+		err = UnsupportedCompressor
+
+		return
+	}
+
+	var (
+		buf bytes.Buffer
+		wc  io.WriteCloser
+	)
+	wc, err = compressor.NewWriter(&buf)
+	if err != nil {
+		return
+	}
+
+	_, err = wc.Write(msg.Content)
+	if err != nil {
+		return
+	}
+
+	err = wc.Close()
+	if err != nil {
+		return
+	}
+
+	msg.Content = buf.Bytes()
+	msg.Compression = compressor.Name()
+
+	return
+}
+
+func (msg *Message) decompress(limit int) (err error) {
+	if msg.Compression == "" {
+		return
+	}
+
+	compressor, ok := GetCompressor(msg.Compression)
+	if !ok {
+		err = UnsupportedCompressor
+
+		return
+	}
+
+	var rc io.ReadCloser
+	rc, err = compressor.NewReader(bytes.NewReader(msg.Content))
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	var r io.Reader = rc
+	if limit > 0 {
+		r = io.LimitReader(rc, int64(limit)+1)
+	}
+
+	var bs []byte
+	bs, err = io.ReadAll(r)
+	if err != nil {
+		return
+	}
+
+	if limit > 0 && len(bs) > limit {
+		err = DecompressionLimitError
+
+		return
+	}
+
+	msg.Content = bs
+	msg.Compression = ""
+
+	return
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Name() (name string) {
+	return GzipCompressorName
+}
+
+func (gzipCompressor) NewWriter(w io.Writer) (wc io.WriteCloser, err error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCompressor) NewReader(r io.Reader) (rc io.ReadCloser, err error) {
+	rc, err = gzip.NewReader(r)
+
+	return
+}
@@ -0,0 +1,130 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	content := bytes.Repeat([]byte("some very important text "), 100)
+
+	msg := Message{
+		Content: content,
+	}
+
+	err := msg.compress(Compression{name: GzipCompressorName, threshold: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Compression != GzipCompressorName || len(msg.Content) >= len(content) {
+		t.Fatalf("content is not compressed: %d bytes", len(msg.Content))
+	}
+
+	err = msg.decompress(len(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Compression != "" || !bytes.Equal(msg.Content, content) {
+		t.Fatal("Origin and Decompressed are not equal")
+	}
+}
+
+func TestCompressionThreshold(t *testing.T) {
+	msg := Message{
+		Content: []byte("short"),
+	}
+
+	err := msg.compress(Compression{name: GzipCompressorName, threshold: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Compression != "" || string(msg.Content) != "short" {
+		t.Fatal("short content is compressed")
+	}
+}
+
+func TestDecompressionLimit(t *testing.T) {
+	msg := Message{
+		Content: bytes.Repeat([]byte{0}, 1<<20),
+	}
+
+	err := msg.compress(Compression{name: GzipCompressorName})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = msg.decompress(DefaultBodyLimit)
+	if !errors.Is(err, DecompressionLimitError) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestCompressedExchange(t *testing.T) {
+	server, err := NewServer(NewTCP("127.0.0.1", newTestPort(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	server.SetHandler("echo", func(ctx context.Context, req Data) (res Data, err error) {
+		res.SetBytes(req.GetBytes())
+
+		return
+	})
+
+	startTestServer(t, server)
+
+	client, err := NewClient(newTestTCP(t, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	settings := NewClientSettings()
+	settings.SetCompression(GzipCompressorName, 0)
+	client.SetSettings(settings)
+
+	content := bytes.Repeat([]byte("some very important text "), 8<<10)
+
+	res, err := client.Send("echo", Data{Bytes: content})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(res.GetBytes(), content) {
+		t.Fatal("Origin and Echoed are not equal")
+	}
+
+	stg := server.Settings()
+	stg.SetDecompressionLimit(DefaultBodyLimit)
+
+	err = server.SetSettings(stg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Send("echo", Data{Bytes: content})
+	if !errors.Is(err, DecompressionLimitError) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if client.tcp.cipherKey == nil {
+		t.Fatal("cipher key is dropped")
+	}
+}
+
+func TestUnsupportedCompressor(t *testing.T) {
+	msg := Message{
+		Content: []byte("some very important text"),
+	}
+
+	err := msg.compress(Compression{name: "unknown"})
+	if !errors.Is(err, UnsupportedCompressor) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
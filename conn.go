@@ -3,6 +3,7 @@ package p2p
 import (
 	"bufio"
 	"encoding/gob"
+	"io"
 	"net"
 	"time"
 )
@@ -10,15 +11,51 @@ import (
 type Conn struct {
 	net.Conn
 	limiter Limiter
+	wire    WireCodec
+
+	reader *bufio.Reader
+	writer *bufio.Writer
+
+	enc    *gob.Encoder
+	dec    *gob.Decoder
+	legacy bool
+
+	bytesIn  int64
+	bytesOut int64
+
+	// deadline limits all operations, e.g. by a request context
+	deadline time.Time
+	// handshake limits operations until a handshake is done
+	handshake time.Time
+	// linked connections wait for packages without the idle timeout
+	linked bool
+
+	info ConnInfo
 }
 
-func NewConn(conn net.Conn, limiter Limiter) (c Conn, err error) {
-	c = Conn{
+func NewConn(conn net.Conn, limiter Limiter) (c *Conn, err error) {
+	c = &Conn{
 		Conn:    conn,
 		limiter: limiter,
 	}
 
-	err = conn.SetDeadline(time.Now().Add(limiter.Timeout.conn))
+	c.writer = bufio.NewWriter(writeCounter{
+		w: conn,
+		n: &c.bytesOut,
+	})
+
+	in := readCounter{
+		r: conn,
+		n: &c.bytesIn,
+	}
+
+	if limiter.body > 0 {
+		c.reader = bufio.NewReaderSize(in, limiter.body)
+	} else {
+		c.reader = bufio.NewReader(in)
+	}
+
+	err = conn.SetDeadline(c.deadlineAfter(limiter.idle))
 	if err != nil {
 		err = PresetConnectionError
 
@@ -28,21 +65,249 @@ func NewConn(conn net.Conn, limiter Limiter) (c Conn, err error) {
 	return
 }
 
+// deadlineAfter returns a deadline of an operation with the timeout.
+func (c *Conn) deadlineAfter(timeout time.Duration) (deadline time.Time) {
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	for _, limit := range []time.Time{c.deadline, c.handshake} {
+		if !limit.IsZero() && (deadline.IsZero() || limit.Before(deadline)) {
+			deadline = limit
+		}
+	}
+
+	return
+}
+
+// limit sets a deadline of all operations, zero time removes it.
+func (c *Conn) limit(deadline time.Time) {
+	c.deadline = deadline
+}
+
+func (c *Conn) beginHandshake() {
+	if c.limiter.handshake > 0 {
+		c.handshake = time.Now().Add(c.limiter.handshake)
+	}
+}
+
+func (c *Conn) endHandshake() {
+	c.handshake = time.Time{}
+}
+
+// link removes limits of requests and turns the idle timeout off.
+func (c *Conn) link() {
+	c.deadline = time.Time{}
+	c.handshake = time.Time{}
+	c.linked = true
+}
+
+// waitPackage waits for the first byte of a package for the idle timeout
+// and gives the read timeout to read the package.
+func (c *Conn) waitPackage() (err error) {
+	if c.reader.Buffered() == 0 {
+		idle := c.limiter.idle
+		if c.linked {
+			idle = 0
+		}
+
+		err = c.Conn.SetReadDeadline(c.deadlineAfter(idle))
+		if err != nil {
+			return PresetConnectionError
+		}
+
+		_, err = c.reader.Peek(1)
+		if err != nil {
+			return
+		}
+	}
+
+	err = c.Conn.SetReadDeadline(c.deadlineAfter(c.limiter.read))
+	if err != nil {
+		return PresetConnectionError
+	}
+
+	return
+}
+
+func (c *Conn) SetWireCodec(wire WireCodec) {
+	c.wire = wire
+}
+
+func (c *Conn) WireCodec() (wire WireCodec) {
+	if c.wire == nil {
+		return GobWire{}
+	}
+
+	return c.wire
+}
+
 func (c *Conn) ReadPackage(p *Package) (err error) {
-	var reader *bufio.Reader
-	if c.limiter.body > 0 {
-		reader = bufio.NewReaderSize(c, c.limiter.body)
-	} else {
-		reader = bufio.NewReader(c)
+	err = c.waitPackage()
+	if err != nil {
+		return
+	}
+
+	if c.wire == nil {
+		c.wire, err = detectWire(c.reader)
+		if err != nil {
+			return
+		}
 	}
 
-	err = gob.NewDecoder(reader).Decode(&p)
+	_, ok := c.wire.(GobWire)
+	if !ok {
+		err = c.wire.ReadPackage(c.reader, p)
+
+		return
+	}
+
+	if c.dec == nil || c.legacy {
+		c.dec = gob.NewDecoder(c.reader)
+	}
+
+	err = c.dec.Decode(p)
 
 	return
 }
 
 func (c *Conn) WritePackage(p Package) (err error) {
-	err = gob.NewEncoder(c).Encode(p)
+	err = c.Conn.SetWriteDeadline(c.deadlineAfter(c.limiter.write))
+	if err != nil {
+		return PresetConnectionError
+	}
+
+	_, ok := c.WireCodec().(GobWire)
+	if ok {
+		if c.enc == nil || c.legacy {
+			c.enc = gob.NewEncoder(c.writer)
+		}
+
+		err = c.enc.Encode(p)
+	} else {
+		err = c.wire.WritePackage(c.writer, p)
+	}
+
+	if err != nil {
+		return
+	}
+
+	err = c.Flush()
+
+	return
+}
+
+func (c *Conn) Flush() (err error) {
+	err = c.writer.Flush()
+
+	return
+}
+
+// setLegacy switches gob to a new encoder and decoder per package
+// for peers that don't keep gob state for a whole connection.
+func (c *Conn) setLegacy(caps Capabilities) {
+	c.legacy = !caps.HasFeature(FeatureGobStream)
+}
+
+// stream starts new gob streams that are kept until the connection is closed.
+// Legacy peers start them after a link is established.
+func (c *Conn) stream() {
+	if !c.legacy {
+		return
+	}
+
+	c.legacy = false
+	c.enc = nil
+	c.dec = nil
+}
+
+func (c *Conn) setPayload(p *Package, val interface{}) (err error) {
+	p.Data, err = c.WireCodec().Marshal(val)
+
+	return
+}
+
+func (c *Conn) getPayload(p Package, val interface{}) (err error) {
+	err = c.WireCodec().Unmarshal(p.Data, val)
+
+	return
+}
+
+// packageError returns an error that is sent in an Error package.
+// Packages without an error text are sent on broken requests.
+func (c *Conn) packageError(p Package) (err error) {
+	var re RemoteError
+	if c.getPayload(p, &re) != nil || re.Text == "" {
+		return ConnectionError
+	}
+
+	return re
+}
+
+func (c *Conn) encodeMessage(msg Message, sk *sessionKey) (cm CryptMessage, err error) {
+	if msg.Error != nil {
+		msg.Error = newRemoteError(msg.Error)
+	}
+
+	_, ok := c.WireCodec().(GobWire)
+	if ok {
+		cm, err = msg.encode(sk)
+
+		return
+	}
+
+	var d Data
+	d, err = c.WireCodec().Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	cm, err = sk.encode(d.Bytes)
+
+	return
+}
+
+func (c *Conn) decodeMessage(cm CryptMessage, sk *sessionKey) (msg Message, err error) {
+	var bs []byte
+	bs, err = sk.open(cm)
+	if err != nil {
+		return
+	}
+
+	err = c.WireCodec().Unmarshal(Data{Bytes: bs}, &msg)
+
+	return
+}
+
+func (c *Conn) contentCodec() (name string) {
+	_, ok := c.WireCodec().(FrameWire)
+	if ok {
+		return JsonCodecName
+	}
+
+	return GobCodecName
+}
+
+type readCounter struct {
+	r io.Reader
+	n *int64
+}
+
+func (rc readCounter) Read(bs []byte) (n int, err error) {
+	n, err = rc.r.Read(bs)
+	*rc.n += int64(n)
+
+	return
+}
+
+type writeCounter struct {
+	w io.Writer
+	n *int64
+}
+
+func (wc writeCounter) Write(bs []byte) (n int, err error) {
+	n, err = wc.w.Write(bs)
+	*wc.n += int64(n)
 
 	return
 }
@@ -0,0 +1,167 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net"
+	"testing"
+	"time"
+)
+
+type bufferConn struct {
+	net.Conn
+	buf bytes.Buffer
+}
+
+func (c *bufferConn) Read(bs []byte) (n int, err error) {
+	return c.buf.Read(bs)
+}
+
+func (c *bufferConn) Write(bs []byte) (n int, err error) {
+	return c.buf.Write(bs)
+}
+
+func (c *bufferConn) SetDeadline(time.Time) (err error) {
+	return
+}
+
+func (c *bufferConn) SetReadDeadline(time.Time) (err error) {
+	return
+}
+
+func (c *bufferConn) SetWriteDeadline(time.Time) (err error) {
+	return
+}
+
+func newBufferConn(t testing.TB, caps Capabilities) (conn *Conn) {
+	conn, err := NewConn(&bufferConn{}, NewClientSettings().Limiter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.setLegacy(caps)
+
+	return
+}
+
+func testPackage(id uint64) (p Package) {
+	p = Package{
+		Type: Reply,
+		ID:   id,
+	}
+	p.SetBytes([]byte("payload"))
+
+	return
+}
+
+func TestConnGobStream(t *testing.T) {
+	conn := newBufferConn(t, localCapabilities())
+
+	for i := uint64(1); i <= 3; i++ {
+		err := conn.WritePackage(testPackage(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	written := conn.Conn.(*bufferConn).buf.Len()
+
+	dec := gob.NewDecoder(&conn.Conn.(*bufferConn).buf)
+	for i := uint64(1); i <= 3; i++ {
+		var p Package
+		err := dec.Decode(&p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p.ID != i || p.String() != "payload" {
+			t.Fatalf("unexpected package %v", p)
+		}
+	}
+
+	legacy := newBufferConn(t, Capabilities{})
+	for i := uint64(1); i <= 3; i++ {
+		err := legacy.WritePackage(testPackage(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if legacy.Conn.(*bufferConn).buf.Len() <= written {
+		t.Fatalf("type info is not resent by legacy connection")
+	}
+}
+
+func TestConnLegacy(t *testing.T) {
+	conn := newBufferConn(t, Capabilities{})
+	buf := &conn.Conn.(*bufferConn).buf
+
+	for i := uint64(1); i <= 3; i++ {
+		err := gob.NewEncoder(buf).Encode(testPackage(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := uint64(1); i <= 3; i++ {
+		var p Package
+		err := conn.ReadPackage(&p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p.ID != i {
+			t.Fatalf("unexpected package %v", p)
+		}
+	}
+
+	conn.stream()
+
+	enc := gob.NewEncoder(buf)
+	for i := uint64(4); i <= 6; i++ {
+		err := enc.Encode(testPackage(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := uint64(4); i <= 6; i++ {
+		var p Package
+		err := conn.ReadPackage(&p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p.ID != i {
+			t.Fatalf("unexpected package %v", p)
+		}
+	}
+}
+
+func benchmarkConn(b *testing.B, caps Capabilities) {
+	conn := newBufferConn(b, caps)
+	p := testPackage(1)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err := conn.WritePackage(p)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		err = conn.ReadPackage(&p)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConnGobStream(b *testing.B) {
+	benchmarkConn(b, localCapabilities())
+}
+
+func BenchmarkConnGobLegacy(b *testing.B) {
+	benchmarkConn(b, Capabilities{})
+}
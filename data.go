@@ -1,55 +1,72 @@
 package p2p
 
-import (
-	"bytes"
-	"encoding/gob"
-	"encoding/json"
-)
-
 type Data struct {
-	Bytes []byte
+	Bytes       []byte
+	ContentType string
 }
 
 func (d *Data) SetBytes(bs []byte) {
 	d.Bytes = bs
+	d.ContentType = ""
 }
 
 func (d *Data) GetBytes() (bs []byte) {
 	return d.Bytes
 }
 
-func (d *Data) SetGob(val interface{}) (err error) {
-	var buf bytes.Buffer
-	err = gob.NewEncoder(&buf).Encode(val)
+func (d *Data) Encode(name string, val interface{}) (err error) {
+	codec, ok := GetCodec(name)
+	if !ok {
+		err = UnsupportedCodec
+
+		return
+	}
+
+	var bs []byte
+	bs, err = codec.Marshal(val)
 	if err != nil {
 		return
 	}
 
-	d.Bytes = buf.Bytes()
+	d.Bytes = bs
+	d.ContentType = codec.Name()
+
+	return
+}
+
+func (d *Data) Decode(val interface{}) (err error) {
+	codec, ok := GetCodec(d.ContentType)
+	if !ok {
+		err = UnsupportedCodec
+
+		return
+	}
+
+	err = codec.Unmarshal(d.Bytes, val)
+
+	return
+}
+
+func (d *Data) SetGob(val interface{}) (err error) {
+	err = d.Encode(GobCodecName, val)
 
 	return
 }
 
 func (d *Data) GetGob(val interface{}) (err error) {
-	err = gob.NewDecoder(bytes.NewReader(d.Bytes)).Decode(val)
+	err = gobCodec{}.Unmarshal(d.Bytes, val)
 
 	return
 }
 
 func (d *Data) SetJson(val interface{}) (err error) {
-	var buf bytes.Buffer
-	err = json.NewEncoder(&buf).Encode(val)
-	if err != nil {
-		return
-	}
-
-	d.Bytes = buf.Bytes()
+	err = d.Encode(JsonCodecName, val)
 
 	return
 }
 
 func (d *Data) GetJson(val interface{}) (err error) {
-	err = json.NewDecoder(bytes.NewReader(d.Bytes)).Decode(val)
+	err = jsonCodec{}.Unmarshal(d.Bytes, val)
 
 	return
 }
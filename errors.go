@@ -1,10 +1,55 @@
 package p2p
 
-import "errors"
+import (
+	"encoding/gob"
+	"errors"
+)
 
 var (
-	UnsupportedPackage    = errors.New("unsupported package type")
-	UnsupportedTopic      = errors.New("unsupported topic")
-	ConnectionError       = errors.New("connection error")
-	PresetConnectionError = errors.New("preset connection error")
+	UnsupportedPackage      = errors.New("unsupported package type")
+	UnsupportedTopic        = errors.New("unsupported topic")
+	ConnectionError         = errors.New("connection error")
+	PresetConnectionError   = errors.New("preset connection error")
+	LinkClosedError         = errors.New("link is closed")
+	UnsupportedCodec        = errors.New("unsupported codec")
+	InvalidService          = errors.New("service has no suitable methods")
+	UnsupportedCompressor   = errors.New("unsupported compressor")
+	DecompressionLimitError = errors.New("decompressed content exceeds decompression limit")
+	ServerClosedError       = errors.New("server is closed")
+	UnsupportedWire         = errors.New("unsupported wire codec")
+	UnsupportedFrame        = errors.New("unsupported frame")
+	FrameSizeError          = errors.New("frame payload exceeds size limit")
+	CipherTextError         = errors.New("cipher text is too short")
+	Overloaded              = errors.New("server is overloaded")
+	RateLimited             = errors.New("rate limit is exceeded")
+	InvalidSettings         = errors.New("invalid settings")
+	UnsupportedLevel        = errors.New("unsupported log level")
 )
+
+type RemoteError struct {
+	Text string
+}
+
+func init() {
+	gob.Register(RemoteError{})
+}
+
+func newRemoteError(err error) (re RemoteError) {
+	var ok bool
+	re, ok = err.(RemoteError)
+	if ok {
+		return
+	}
+
+	return RemoteError{
+		Text: err.Error(),
+	}
+}
+
+func (re RemoteError) Error() (str string) {
+	return re.Text
+}
+
+func (re RemoteError) Is(target error) (ok bool) {
+	return target != nil && target.Error() == re.Text
+}
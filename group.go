@@ -0,0 +1,93 @@
+package p2p
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+type Middleware func(next Handler) (handler Handler)
+
+type Group struct {
+	router *Router
+	parent *Group
+	prefix string
+
+	mx          sync.RWMutex
+	middlewares []Middleware
+}
+
+func newGroup(router *Router, parent *Group, prefix string) (g *Group) {
+	return &Group{
+		router: router,
+		parent: parent,
+		prefix: strings.Trim(prefix, TopicSeparator),
+
+		mx: sync.RWMutex{},
+	}
+}
+
+func (g *Group) Use(middlewares ...Middleware) {
+	g.mx.Lock()
+	g.middlewares = append(g.middlewares, middlewares...)
+	g.mx.Unlock()
+}
+
+func (g *Group) Group(prefix string) (sub *Group) {
+	return newGroup(g.router, g, g.topic(prefix))
+}
+
+func (g *Group) SetHandler(topic string, handler Handler) {
+	g.router.SetHandler(g.topic(topic), g.wrap(handler))
+}
+
+func (g *Group) setSchema(topic string, schema Schema) {
+	g.router.setSchema(g.topic(topic), schema)
+}
+
+func (g *Group) RemoveHandler(topic string) (ok bool) {
+	return g.router.RemoveHandler(g.topic(topic))
+}
+
+func (g *Group) Handlers() (topics []string) {
+	for _, topic := range g.router.Handlers() {
+		if g.prefix == "" || topic == g.prefix || strings.HasPrefix(topic, g.prefix+TopicSeparator) {
+			topics = append(topics, topic)
+		}
+	}
+
+	return
+}
+
+func (g *Group) topic(topic string) (full string) {
+	topic = strings.Trim(topic, TopicSeparator)
+	if g.prefix == "" {
+		return topic
+	}
+
+	if topic == "" {
+		return g.prefix
+	}
+
+	return g.prefix + TopicSeparator + topic
+}
+
+func (g *Group) wrap(handler Handler) (wrapped Handler) {
+	return func(ctx context.Context, req Data) (res Data, err error) {
+		return g.chain(handler)(ctx, req)
+	}
+}
+
+func (g *Group) chain(handler Handler) (chained Handler) {
+	g.mx.RLock()
+	for i := len(g.middlewares) - 1; i >= 0; i-- {
+		handler = g.middlewares[i](handler)
+	}
+	g.mx.RUnlock()
+
+	if g.parent != nil {
+		handler = g.parent.chain(handler)
+	}
+
+	return handler
+}
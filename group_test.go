@@ -0,0 +1,90 @@
+package p2p
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func tagMiddleware(tag string) (mw Middleware) {
+	return func(next Handler) (handler Handler) {
+		return func(ctx context.Context, req Data) (res Data, err error) {
+			res, err = next(ctx, req)
+			res.SetBytes(append([]byte(tag), res.GetBytes()...))
+
+			return
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	r := NewRouter()
+	r.SetHandler("ping", namedHandler("ping"))
+
+	orders := r.Group("orders")
+	orders.SetHandler("created", namedHandler("created"))
+
+	eu := orders.Group("eu")
+	eu.SetHandler("created", namedHandler("created"))
+
+	orders.Use(tagMiddleware("orders:"))
+	eu.Use(tagMiddleware("eu:"))
+
+	for topic, want := range map[string]string{
+		"ping":              "ping",
+		"orders.created":    "orders:created",
+		"orders.eu.created": "orders:eu:created",
+	} {
+		handler, _, ok := r.Lookup(topic)
+		if !ok {
+			t.Fatalf("%s: handler is not found", topic)
+		}
+
+		res, _ := handler(context.Background(), Data{})
+		if res.String() != want {
+			t.Fatalf("%s: unexpected response %s", topic, res.String())
+		}
+	}
+
+	if !reflect.DeepEqual(orders.Handlers(), []string{"orders.created", "orders.eu.created"}) {
+		t.Fatalf("unexpected handlers %v", orders.Handlers())
+	}
+
+	if !eu.RemoveHandler("created") || eu.RemoveHandler("created") {
+		t.Fatal("handler is not removed once")
+	}
+
+	if !reflect.DeepEqual(r.Handlers(), []string{"orders.created", "ping"}) {
+		t.Fatalf("unexpected handlers %v", r.Handlers())
+	}
+}
+
+func TestRouterConcurrency(t *testing.T) {
+	r := NewRouter()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				r.SetHandler("orders.*", namedHandler("orders"))
+				r.RemoveHandler("orders.*")
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				r.Lookup("orders.created")
+				r.Handlers()
+			}
+		}()
+	}
+
+	wg.Wait()
+}
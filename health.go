@@ -0,0 +1,153 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+)
+
+const HealthTopic = "p2p.health"
+
+type ServingStatus uint8
+
+const (
+	StatusUnknown ServingStatus = iota
+	StatusServing
+	StatusNotServing
+)
+
+var statusNames = map[ServingStatus]string{
+	StatusUnknown:    "UNKNOWN",
+	StatusServing:    "SERVING",
+	StatusNotServing: "NOT_SERVING",
+}
+
+func (st ServingStatus) String() (str string) {
+	str, ok := statusNames[st]
+	if !ok {
+		str = statusNames[StatusUnknown]
+	}
+
+	return
+}
+
+func (st ServingStatus) MarshalText() (bs []byte, err error) {
+	return []byte(st.String()), nil
+}
+
+func (st *ServingStatus) UnmarshalText(bs []byte) (err error) {
+	*st = StatusUnknown
+
+	for status, name := range statusNames {
+		if name == string(bs) {
+			*st = status
+		}
+	}
+
+	return
+}
+
+type HealthRequest struct {
+	Component string `json:"component"`
+}
+
+type HealthResponse struct {
+	Component string        `json:"component"`
+	Status    ServingStatus `json:"status"`
+}
+
+type health struct {
+	mx       sync.RWMutex
+	statuses map[string]ServingStatus
+	shutdown bool
+}
+
+func newHealth() (h *health) {
+	return &health{
+		mx: sync.RWMutex{},
+		statuses: map[string]ServingStatus{
+			"": StatusServing,
+		},
+	}
+}
+
+func (h *health) set(component string, status ServingStatus) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	if h.shutdown {
+		return
+	}
+
+	h.statuses[component] = status
+}
+
+func (h *health) get(component string) (status ServingStatus) {
+	h.mx.RLock()
+	status = h.statuses[component]
+	h.mx.RUnlock()
+
+	return
+}
+
+func (h *health) stop() {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	h.shutdown = true
+	for component := range h.statuses {
+		h.statuses[component] = StatusNotServing
+	}
+}
+
+func (h *health) handle(ctx context.Context, req Data) (res Data, err error) {
+	var hr HealthRequest
+	if len(req.GetBytes()) > 0 {
+		err = req.GetJson(&hr)
+		if err != nil {
+			return
+		}
+	}
+
+	err = res.SetJson(HealthResponse{
+		Component: hr.Component,
+		Status:    h.get(hr.Component),
+	})
+
+	return
+}
+
+func (s *Server) SetServingStatus(component string, status ServingStatus) {
+	s.health.set(component, status)
+}
+
+func (c *Client) Health(ctx context.Context) (status ServingStatus, err error) {
+	status, err = c.ComponentHealth(ctx, "")
+
+	return
+}
+
+func (c *Client) ComponentHealth(ctx context.Context, component string) (status ServingStatus, err error) {
+	var req Data
+	err = req.SetJson(HealthRequest{
+		Component: component,
+	})
+	if err != nil {
+		return
+	}
+
+	var res Data
+	res, _, err = c.SendContext(ctx, HealthTopic, req, nil)
+	if err != nil {
+		return
+	}
+
+	var hr HealthResponse
+	err = res.GetJson(&hr)
+	if err != nil {
+		return
+	}
+
+	status = hr.Status
+
+	return
+}
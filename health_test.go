@@ -0,0 +1,99 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	port := newTestPort(t)
+
+	server, err := NewServer(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	settings := NewServerSettings()
+	settings.SetShutdownDelay(200 * time.Millisecond)
+	err = server.SetSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve()
+	}()
+
+	client, err := NewClient(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	ctx := context.Background()
+
+	var status ServingStatus
+	for i := 0; i < 10; i++ {
+		status, err = client.Health(ctx)
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil || status != StatusServing {
+		t.Fatalf("unexpected status %s (%v)", status, err)
+	}
+
+	server.SetServingStatus("db", StatusNotServing)
+
+	status, err = client.ComponentHealth(ctx, "db")
+	if err != nil || status != StatusNotServing {
+		t.Fatalf("unexpected db status %s (%v)", status, err)
+	}
+
+	status, err = client.ComponentHealth(ctx, "cache")
+	if err != nil || status != StatusUnknown {
+		t.Fatalf("unexpected cache status %s (%v)", status, err)
+	}
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- server.Shutdown(ctx)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	status, err = client.Health(ctx)
+	if err != nil || status != StatusNotServing {
+		t.Fatalf("unexpected status during shutdown %s (%v)", status, err)
+	}
+
+	err = <-shutdown
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = <-served
+	if !errors.Is(err, ServerClosedError) {
+		t.Fatalf("unexpected serve error %v", err)
+	}
+}
+
+func TestServingStatusText(t *testing.T) {
+	for _, status := range []ServingStatus{StatusUnknown, StatusServing, StatusNotServing} {
+		bs, err := status.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var parsed ServingStatus
+		err = parsed.UnmarshalText(bs)
+		if err != nil || parsed != status {
+			t.Fatalf("unexpected status %s (%v)", parsed, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package p2p
+
+import "sync/atomic"
+
+type ConnInfo struct {
+	ID         uint64
+	Role       string
+	LocalAddr  string
+	RemoteAddr string
+}
+
+type HandshakeInfo struct {
+	ConnInfo
+	Session Session
+	// Fingerprint identifies the client public key, it's empty on the client side.
+	Fingerprint string
+}
+
+// Hooks are called synchronously on connection events, nil hooks are skipped.
+// ConnClosed gets a nil reason when a connection is closed after a finished exchange or link.
+type Hooks struct {
+	ConnOpened func(info ConnInfo)
+	Handshake  func(info HandshakeInfo)
+	Exchange   func(info ConnInfo, stats RequestStats)
+	ConnClosed func(info ConnInfo, reason error)
+}
+
+func (h Hooks) connOpened(info ConnInfo) {
+	if h.ConnOpened != nil {
+		h.ConnOpened(info)
+	}
+}
+
+func (h Hooks) handshake(info HandshakeInfo) {
+	if h.Handshake != nil {
+		h.Handshake(info)
+	}
+}
+
+func (h Hooks) exchange(info ConnInfo, stats RequestStats) {
+	if h.Exchange != nil {
+		h.Exchange(info, stats)
+	}
+}
+
+func (h Hooks) connClosed(info ConnInfo, reason error) {
+	if h.ConnClosed != nil {
+		h.ConnClosed(info, reason)
+	}
+}
+
+func newConnInfo(seq *uint64, role string, conn *Conn) (info ConnInfo) {
+	return ConnInfo{
+		ID:         atomic.AddUint64(seq, 1),
+		Role:       role,
+		LocalAddr:  conn.LocalAddr().String(),
+		RemoteAddr: conn.RemoteAddr().String(),
+	}
+}
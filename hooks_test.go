@@ -0,0 +1,152 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type hookRecorder struct {
+	mx     sync.Mutex
+	events map[uint64][]string
+	infos  []HandshakeInfo
+	closed chan error
+}
+
+func newHookRecorder() (r *hookRecorder) {
+	return &hookRecorder{
+		events: map[uint64][]string{},
+		closed: make(chan error, 8),
+	}
+}
+
+func (r *hookRecorder) add(id uint64, event string) {
+	r.mx.Lock()
+	r.events[id] = append(r.events[id], event)
+	r.mx.Unlock()
+}
+
+func (r *hookRecorder) get(id uint64) (events []string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	return r.events[id]
+}
+
+func (r *hookRecorder) hooks() (hooks Hooks) {
+	return Hooks{
+		ConnOpened: func(info ConnInfo) {
+			r.add(info.ID, "opened")
+		},
+		Handshake: func(info HandshakeInfo) {
+			r.mx.Lock()
+			r.infos = append(r.infos, info)
+			r.mx.Unlock()
+
+			r.add(info.ID, "handshake")
+		},
+		Exchange: func(info ConnInfo, stats RequestStats) {
+			r.add(info.ID, "exchange")
+		},
+		ConnClosed: func(info ConnInfo, reason error) {
+			r.add(info.ID, "closed")
+			r.closed <- reason
+		},
+	}
+}
+
+func (r *hookRecorder) wait(t *testing.T) (reason error) {
+	t.Helper()
+
+	select {
+	case reason = <-r.closed:
+	case <-time.After(time.Second):
+		t.Fatal("connection close hook is not called")
+	}
+
+	return
+}
+
+func TestHooks(t *testing.T) {
+	port := newTestPort(t)
+
+	server, err := NewServer(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	server.SetHandler("echo", func(ctx context.Context, req Data) (res Data, err error) {
+		return req, nil
+	})
+
+	serverHooks := newHookRecorder()
+	server.SetHooks(serverHooks.hooks())
+
+	startTestServer(t, server)
+
+	client, err := NewClient(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	settings := NewClientSettings()
+	settings.SetRetry(1, 0)
+	client.SetSettings(settings)
+
+	clientHooks := newHookRecorder()
+	client.SetHooks(clientHooks.hooks())
+
+	_, err = client.Send("echo", Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reason := clientHooks.wait(t); reason != nil {
+		t.Fatalf("unexpected client reason %v", reason)
+	}
+
+	// the first server connection is the probe of startTestServer
+	if reason := serverHooks.wait(t); reason == nil {
+		t.Fatal("probe reason is expected")
+	}
+
+	if reason := serverHooks.wait(t); reason != nil {
+		t.Fatalf("unexpected server reason %v", reason)
+	}
+
+	if events := serverHooks.get(1); !equalStrings(events, []string{"opened", "closed"}) {
+		t.Fatalf("unexpected probe events %v", events)
+	}
+
+	expected := []string{"opened", "handshake", "exchange", "closed"}
+	if events := serverHooks.get(2); !equalStrings(events, expected) {
+		t.Fatalf("unexpected server events %v", events)
+	}
+
+	if events := clientHooks.get(1); !equalStrings(events, expected) {
+		t.Fatalf("unexpected client events %v", events)
+	}
+
+	info := serverHooks.infos[0]
+	if info.Role != ServerRole || info.ID == 0 || info.RemoteAddr == "" ||
+		info.Session.CipherSuite != CipherSuiteAES128GCM || info.Fingerprint != client.rsa.PublicKey().Fingerprint() {
+		t.Fatalf("unexpected server handshake info %+v", info)
+	}
+
+	info = clientHooks.infos[0]
+	if info.Role != ClientRole || info.RemoteAddr != server.tcp.addr || info.Fingerprint != "" {
+		t.Fatalf("unexpected client handshake info %+v", info)
+	}
+
+	_, err = client.Send("unknown", Data{})
+	if err == nil {
+		t.Fatal("error is expected")
+	}
+
+	if reason := clientHooks.wait(t); reason == nil {
+		t.Fatal("client reason is expected")
+	}
+}
@@ -0,0 +1,171 @@
+package p2p
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	ClientRole = "client"
+	ServerRole = "server"
+)
+
+const (
+	defaultRoute     = "default"
+	unsupportedRoute = "unsupported"
+)
+
+type phaseKey struct {
+	topic string
+	phase string
+}
+
+type instruments struct {
+	role    string
+	buckets []float64
+
+	mx       sync.Mutex
+	requests map[string]float64
+	errors   map[string]float64
+	inFlight float64
+	phases   map[phaseKey]*Histogram
+}
+
+func newInstruments(role string) (in *instruments) {
+	return &instruments{
+		role:    role,
+		buckets: DefaultBuckets,
+
+		mx:       sync.Mutex{},
+		requests: map[string]float64{},
+		errors:   map[string]float64{},
+		phases:   map[phaseKey]*Histogram{},
+	}
+}
+
+func (in *instruments) begin() {
+	in.mx.Lock()
+	in.inFlight++
+	in.mx.Unlock()
+}
+
+func (in *instruments) end(m *Metrics, err error) {
+	topic := m.route
+	if topic == "" {
+		topic = m.topic
+	}
+
+	if topic == "" {
+		topic = unsupportedRoute
+	}
+
+	if err == nil {
+		err = m.err
+	}
+
+	in.mx.Lock()
+	defer in.mx.Unlock()
+
+	in.inFlight--
+	in.requests[topic]++
+
+	if err != nil {
+		in.errors[topic]++
+	}
+
+	for _, phase := range m.phases() {
+		key := phaseKey{
+			topic: topic,
+			phase: phase.name,
+		}
+
+		h, ok := in.phases[key]
+		if !ok {
+			h = newHistogram(in.buckets)
+			in.phases[key] = h
+		}
+
+		h.observe(phase.dur.Seconds())
+	}
+}
+
+func (in *instruments) Collect() (families []MetricFamily) {
+	in.mx.Lock()
+	defer in.mx.Unlock()
+
+	families = []MetricFamily{
+		{
+			Name:    "p2p_requests_total",
+			Help:    "Number of finished requests.",
+			Type:    CounterMetric,
+			Metrics: in.counters(in.requests),
+		},
+		{
+			Name:    "p2p_request_errors_total",
+			Help:    "Number of failed requests.",
+			Type:    CounterMetric,
+			Metrics: in.counters(in.errors),
+		},
+		{
+			Name: "p2p_requests_in_flight",
+			Help: "Number of requests in progress.",
+			Type: GaugeMetric,
+			Metrics: []Metric{{
+				Labels: map[string]string{"role": in.role},
+				Value:  in.inFlight,
+			}},
+		},
+		{
+			Name: "p2p_phase_duration_seconds",
+			Help: "Duration of request phases: handshake, read, handle and write.",
+			Type: HistogramMetric,
+		},
+	}
+
+	keys := make([]phaseKey, 0, len(in.phases))
+	for key := range in.phases {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].topic != keys[j].topic {
+			return keys[i].topic < keys[j].topic
+		}
+
+		return keys[i].phase < keys[j].phase
+	})
+
+	for _, key := range keys {
+		families[3].Metrics = append(families[3].Metrics, Metric{
+			Labels: map[string]string{
+				"role":  in.role,
+				"topic": key.topic,
+				"phase": key.phase,
+			},
+			Histogram: in.phases[key].copy(),
+		})
+	}
+
+	return
+}
+
+func (in *instruments) counters(values map[string]float64) (metrics []Metric) {
+	topics := make([]string, 0, len(values))
+	for topic := range values {
+		topics = append(topics, topic)
+	}
+
+	sort.Strings(topics)
+
+	for _, topic := range topics {
+		metrics = append(metrics, Metric{
+			Labels: map[string]string{
+				"role":  in.role,
+				"topic": topic,
+			},
+			Value: values[topic],
+		})
+	}
+
+	return
+}
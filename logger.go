@@ -2,7 +2,11 @@ package p2p
 
 import (
 	"fmt"
+	"math/rand"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type Logger interface {
@@ -11,20 +15,233 @@ type Logger interface {
 	Error(msg string)
 }
 
-type stdLogger struct{}
+// Level values match log/slog levels.
+type Level int
+
+const (
+	DebugLevel Level = -4
+	InfoLevel  Level = 0
+	WarnLevel  Level = 4
+	ErrorLevel Level = 8
+)
+
+func (level Level) String() (str string) {
+	switch {
+	case level < InfoLevel:
+		return "DEBUG"
+	case level < WarnLevel:
+		return "INFO"
+	case level < ErrorLevel:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+func (level Level) MarshalText() (text []byte, err error) {
+	return []byte(strings.ToLower(level.String())), nil
+}
+
+func (level *Level) UnmarshalText(text []byte) (err error) {
+	switch strings.ToUpper(string(text)) {
+	case "DEBUG":
+		*level = DebugLevel
+	case "INFO":
+		*level = InfoLevel
+	case "WARN":
+		*level = WarnLevel
+	case "ERROR":
+		*level = ErrorLevel
+	default:
+		err = UnsupportedLevel
+	}
+
+	return
+}
+
+// levelVar is a minimum level of records that is shared by copies of a logger.
+type levelVar struct {
+	level int64
+}
+
+func newLevelVar(level Level) (v *levelVar) {
+	return &levelVar{
+		level: int64(level),
+	}
+}
+
+func (v *levelVar) get() (level Level) {
+	return Level(atomic.LoadInt64(&v.level))
+}
+
+func (v *levelVar) set(level Level) {
+	atomic.StoreInt64(&v.level, int64(level))
+}
+
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// StructuredLogger is used instead of Logger methods
+// when a logger passed to SetLogger implements it.
+type StructuredLogger interface {
+	Enabled(level Level) (ok bool)
+	Log(level Level, msg string, fields ...Field)
+}
+
+type stdLogger struct {
+	mx    sync.RWMutex
+	level Level
+}
 
 func NewStdLogger() (l *stdLogger) {
-	return &stdLogger{}
+	return &stdLogger{
+		mx:    sync.RWMutex{},
+		level: InfoLevel,
+	}
+}
+
+func (l *stdLogger) SetLevel(level Level) {
+	l.mx.Lock()
+	l.level = level
+	l.mx.Unlock()
+}
+
+func (l *stdLogger) Enabled(level Level) (ok bool) {
+	l.mx.RLock()
+	defer l.mx.RUnlock()
+
+	return level >= l.level
+}
+
+func (l *stdLogger) Log(level Level, msg string, fields ...Field) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	_, _ = fmt.Fprintln(os.Stderr, level.String(), formatFields(msg, fields))
 }
 
 func (l *stdLogger) Info(msg string) {
-	_, _ = fmt.Fprintln(os.Stderr, msg)
+	l.Log(InfoLevel, msg)
 }
 
 func (l *stdLogger) Warn(msg string) {
-	_, _ = fmt.Fprintln(os.Stderr, msg)
+	l.Log(WarnLevel, msg)
 }
 
 func (l *stdLogger) Error(msg string) {
-	_, _ = fmt.Fprintln(os.Stderr, msg)
+	l.Log(ErrorLevel, msg)
+}
+
+func formatFields(msg string, fields []Field) (line string) {
+	if len(fields) == 0 {
+		return msg
+	}
+
+	var sb strings.Builder
+	sb.WriteString(msg)
+
+	for _, field := range fields {
+		_, _ = fmt.Fprintf(&sb, " %s=%v", field.Key, field.Value)
+	}
+
+	return sb.String()
+}
+
+// plainLogger passes fields of structured records to Logger inside the message.
+type plainLogger struct {
+	logger Logger
+}
+
+func (l plainLogger) Enabled(level Level) (ok bool) {
+	return level >= InfoLevel
+}
+
+func (l plainLogger) Log(level Level, msg string, fields ...Field) {
+	msg = formatFields(msg, fields)
+
+	switch {
+	case level < WarnLevel:
+		l.logger.Info(msg)
+	case level < ErrorLevel:
+		l.logger.Warn(msg)
+	default:
+		l.logger.Error(msg)
+	}
+}
+
+type fieldLogger struct {
+	logger StructuredLogger
+	fields []Field
+	level  *levelVar
+}
+
+func newFieldLogger(logger Logger) (l fieldLogger) {
+	sl, ok := logger.(StructuredLogger)
+	if !ok {
+		sl = plainLogger{
+			logger: logger,
+		}
+	}
+
+	return fieldLogger{
+		logger: sl,
+	}
+}
+
+func (l fieldLogger) with(fields ...Field) (fl fieldLogger) {
+	fl = l
+	fl.fields = append(append([]Field(nil), l.fields...), fields...)
+
+	return
+}
+
+func (l fieldLogger) withLevel(level *levelVar) (fl fieldLogger) {
+	fl = l
+	fl.level = level
+
+	return
+}
+
+func (l fieldLogger) log(level Level, msg string, fields []Field) {
+	if l.level != nil && level < l.level.get() {
+		return
+	}
+
+	if !l.logger.Enabled(level) {
+		return
+	}
+
+	if len(l.fields) > 0 {
+		fields = append(append([]Field(nil), l.fields...), fields...)
+	}
+
+	l.logger.Log(level, msg, fields...)
+}
+
+func (l fieldLogger) Debug(msg string, fields ...Field) {
+	l.log(DebugLevel, msg, fields)
+}
+
+func (l fieldLogger) Info(msg string, fields ...Field) {
+	l.log(InfoLevel, msg, fields)
+}
+
+func (l fieldLogger) Warn(msg string, fields ...Field) {
+	l.log(WarnLevel, msg, fields)
+}
+
+func (l fieldLogger) Error(msg string, fields ...Field) {
+	l.log(ErrorLevel, msg, fields)
+}
+
+// access logs a finished request when it's sampled.
+func (l fieldLogger) access(m *Metrics, sampling float64) {
+	if sampling <= 0 || sampling < 1 && rand.Float64() >= sampling {
+		return
+	}
+
+	l.Info("request", m.fields()...)
 }
@@ -0,0 +1,75 @@
+package p2p
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordLogger struct {
+	lines []string
+}
+
+func (l *recordLogger) Info(msg string) {
+	l.lines = append(l.lines, "INFO "+msg)
+}
+
+func (l *recordLogger) Warn(msg string) {
+	l.lines = append(l.lines, "WARN "+msg)
+}
+
+func (l *recordLogger) Error(msg string) {
+	l.lines = append(l.lines, "ERROR "+msg)
+}
+
+func TestPlainLogger(t *testing.T) {
+	rl := &recordLogger{}
+	logger := newFieldLogger(rl).with(Field{Key: "addr", Value: "127.0.0.1:8080"})
+
+	logger.Debug("skipped")
+	logger.Warn("unsupported topic", Field{Key: "topic", Value: "ping"})
+
+	if len(rl.lines) != 1 || rl.lines[0] != "WARN unsupported topic addr=127.0.0.1:8080 topic=ping" {
+		t.Fatalf("unexpected lines %q", rl.lines)
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	metrics := newMetrics("127.0.0.1:8080")
+	metrics.setTopic("ping")
+	metrics.handle = time.Millisecond
+
+	rl := &recordLogger{}
+	logger := newFieldLogger(rl)
+
+	logger.access(metrics, 0)
+	if len(rl.lines) != 0 {
+		t.Fatalf("access log is not turned off: %q", rl.lines)
+	}
+
+	logger.access(metrics, DefaultAccessLogSampling)
+	if len(rl.lines) != 1 || !strings.HasPrefix(rl.lines[0], "INFO request topic=ping addr=127.0.0.1:8080 handle=1ms total=1ms") {
+		t.Fatalf("unexpected lines %q", rl.lines)
+	}
+
+	rl.lines = nil
+	for i := 0; i < 1000; i++ {
+		logger.access(metrics, 0.1)
+	}
+
+	if len(rl.lines) == 0 || len(rl.lines) > 300 {
+		t.Fatalf("unexpected number of sampled lines %d", len(rl.lines))
+	}
+}
+
+func TestStdLoggerLevel(t *testing.T) {
+	logger := NewStdLogger()
+	if logger.Enabled(DebugLevel) || !logger.Enabled(InfoLevel) {
+		t.Fatal("unexpected default level")
+	}
+
+	logger.SetLevel(ErrorLevel)
+	if logger.Enabled(WarnLevel) || !logger.Enabled(ErrorLevel) {
+		t.Fatal("level is not changed")
+	}
+}
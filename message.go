@@ -6,26 +6,58 @@ import (
 )
 
 type Message struct {
-	Topic   string
-	Content []byte
-	Error   error
+	Topic       string
+	Content     []byte
+	ContentType string
+	Compression string
+	Error       error
+	Metadata    Metadata
 }
 
 type CryptMessage []byte
 
+func (msg Message) data() (d Data) {
+	return Data{
+		Bytes:       msg.Content,
+		ContentType: msg.ContentType,
+	}
+}
+
+func (msg *Message) setData(d Data) {
+	msg.Content = d.Bytes
+	msg.ContentType = d.ContentType
+}
+
+// Encode makes an AEAD of the key on every call, connections encode messages by the AEAD of their session key.
 func (msg Message) Encode(ck CipherKey) (cm CryptMessage, err error) {
-	var buf bytes.Buffer
+	var sk *sessionKey
+	sk, err = newSessionKey(ck)
+	if err != nil {
+		return
+	}
+
+	return msg.encode(sk)
+}
+
+func (msg Message) encode(sk *sessionKey) (cm CryptMessage, err error) {
+	if msg.Error != nil {
+		msg.Error = newRemoteError(msg.Error)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
 
-	err = gob.NewEncoder(&buf).Encode(msg)
+	err = gob.NewEncoder(buf).Encode(msg)
 	if err != nil {
 		return
 	}
 
-	cm, err = ck.Encode(buf.Bytes())
+	cm, err = sk.encode(buf.Bytes())
 
 	return
 }
 
+// Decode makes an AEAD of the key on every call like Encode.
 func (cm CryptMessage) Decode(ck CipherKey) (msg Message, err error) {
 	var bs []byte
 	bs, err = ck.Decode(cm)
@@ -1,6 +1,7 @@
 package p2p
 
 import (
+	"bytes"
 	"testing"
 )
 
@@ -14,6 +15,9 @@ func TestMessage(t *testing.T) {
 		Topic:   "topic",
 		Content: []byte("some very important text"),
 		Error:   nil,
+		Metadata: Metadata{
+			"content-type": "text/plain",
+		},
 	}
 
 	var cm CryptMessage
@@ -30,7 +34,119 @@ func TestMessage(t *testing.T) {
 
 	if msg.Topic != newMsg.Topic ||
 		string(msg.Content) != string(newMsg.Content) ||
-		msg.Error != msg.Error {
+		msg.Error != msg.Error ||
+		msg.Metadata.Get("content-type") != newMsg.Metadata.Get("content-type") {
 		t.Fatal(err)
 	}
 }
+
+func benchmarkMessage() (msg Message) {
+	return Message{
+		Topic:   "topic",
+		Content: bytes.Repeat([]byte("some very important text"), 40),
+		Metadata: Metadata{
+			"content-type": "text/plain",
+		},
+	}
+}
+
+func BenchmarkMessageEncodeParallel(b *testing.B) {
+	key, err := NewCipherKey()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	msg := benchmarkMessage()
+
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := msg.Encode(key)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkMessageEncodeSessionKeyParallel(b *testing.B) {
+	ck, err := NewCipherKey()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	key, err := newSessionKey(ck)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	msg := benchmarkMessage()
+
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := msg.encode(key)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkMessageDecodeParallel(b *testing.B) {
+	key, err := NewCipherKey()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var cm CryptMessage
+	cm, err = benchmarkMessage().Encode(key)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := cm.Decode(key)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkConnMessageParallel(b *testing.B) {
+	ck, err := NewCipherKey()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	key, err := newSessionKey(ck)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	msg := benchmarkMessage()
+
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		conn := newBufferConn(b, localCapabilities())
+
+		for pb.Next() {
+			cm, err := conn.encodeMessage(msg, key)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			_, err = conn.decodeMessage(cm, key)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
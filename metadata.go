@@ -0,0 +1,94 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+)
+
+type Metadata map[string]string
+
+func (md Metadata) Get(key string) (value string) {
+	return md[key]
+}
+
+func (md Metadata) Set(key, value string) {
+	md[key] = value
+}
+
+func (md Metadata) Keys() (keys []string) {
+	keys = make([]string, 0, len(md))
+	for key := range md {
+		keys = append(keys, key)
+	}
+
+	return
+}
+
+func (md Metadata) Copy() (cp Metadata) {
+	if md == nil {
+		return
+	}
+
+	cp = make(Metadata, len(md))
+	for key, value := range md {
+		cp[key] = value
+	}
+
+	return
+}
+
+type metadataKey struct{}
+
+type metadataHolder struct {
+	in Metadata
+
+	mx  sync.Mutex
+	out Metadata
+}
+
+func withMetadata(ctx context.Context, in Metadata) (c context.Context, holder *metadataHolder) {
+	holder = &metadataHolder{
+		in:  in,
+		out: Metadata{},
+	}
+
+	return context.WithValue(ctx, metadataKey{}, holder), holder
+}
+
+func (h *metadataHolder) outgoing() (md Metadata) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	if len(h.out) == 0 {
+		return
+	}
+
+	return h.out.Copy()
+}
+
+func IncomingMetadata(ctx context.Context) (md Metadata) {
+	holder, ok := ctx.Value(metadataKey{}).(*metadataHolder)
+	if !ok {
+		return Metadata{}
+	}
+
+	md = holder.in.Copy()
+	if md == nil {
+		md = Metadata{}
+	}
+
+	return
+}
+
+func SetResponseMetadata(ctx context.Context, key, value string) (ok bool) {
+	holder, ok := ctx.Value(metadataKey{}).(*metadataHolder)
+	if !ok {
+		return
+	}
+
+	holder.mx.Lock()
+	holder.out.Set(key, value)
+	holder.mx.Unlock()
+
+	return
+}
@@ -1,14 +1,17 @@
 package p2p
 
-import (
-	"fmt"
-	"strings"
-	"time"
-)
+import "time"
 
 type Metrics struct {
 	topic string
+	route string
 	addr  string
+	err   error
+
+	bytesIn  int64
+	bytesOut int64
+
+	span Span
 
 	tm time.Time
 
@@ -16,8 +19,6 @@ type Metrics struct {
 	read      time.Duration
 	handle    time.Duration
 	write     time.Duration
-
-	stat []string
 }
 
 func newMetrics(addr string) (m *Metrics) {
@@ -29,8 +30,6 @@ func newMetrics(addr string) (m *Metrics) {
 		read:      -1,
 		handle:    -1,
 		write:     -1,
-
-		stat: []string{fmt.Sprintf("addr (%s)", addr)},
 	}
 }
 
@@ -42,77 +41,95 @@ func (m *Metrics) setTopic(topic string) {
 	m.topic = topic
 }
 
-const statPattern = "%s (%s)"
+func (m *Metrics) setRoute(route string) {
+	m.route = route
+}
+
+func (m *Metrics) setError(err error) {
+	m.err = err
+}
+
+func (m *Metrics) setBytes(conn *Conn) {
+	m.bytesIn = conn.bytesIn
+	m.bytesOut = conn.bytesOut
+}
+
+// setSpan adds events of phases that are already passed to the span.
+func (m *Metrics) setSpan(span Span) {
+	m.span = span
+
+	for _, p := range m.phases() {
+		span.AddEvent(p.name, p.dur)
+	}
+}
+
+func (m *Metrics) event(name string, dur time.Duration) {
+	if m.span != nil {
+		m.span.AddEvent(name, dur)
+	}
+}
 
 func (m *Metrics) fixHandshake() {
 	m.handshake = time.Since(m.tm)
-	m.stat = append(m.stat, fmt.Sprintf(statPattern, "handshake", prepareValue(m.handshake)))
+	m.event("handshake", m.handshake)
 	m.reset()
 }
 
 func (m *Metrics) fixReadDuration() {
 	m.read = time.Since(m.tm)
-	m.stat = append(m.stat, fmt.Sprintf(statPattern, "read", prepareValue(m.read)))
+	m.event("read", m.read)
 	m.reset()
 }
 
 func (m *Metrics) fixHandleDuration() {
 	m.handle = time.Since(m.tm)
-	m.stat = append(m.stat, fmt.Sprintf(statPattern, "handle", prepareValue(m.handle)))
+	m.event("handle", m.handle)
 	m.reset()
 }
 
 func (m *Metrics) fixWriteDuration() {
 	m.write = time.Since(m.tm)
-	m.stat = append(m.stat, fmt.Sprintf(statPattern, "write", prepareValue(m.write)))
+	m.event("write", m.write)
 	m.reset()
 }
 
-func (m *Metrics) string() (line string) {
-	var total time.Duration
-
-	if m.handshake >= 0 {
-		total += m.handshake
-	}
-
-	if m.read >= 0 {
-		total += m.read
-	}
-
-	if m.handle >= 0 {
-		total += m.handle
-	}
-
-	if m.write >= 0 {
-		total += m.write
-	}
+type phase struct {
+	name string
+	dur  time.Duration
+}
 
-	if total > 0 {
-		m.stat = append(m.stat, fmt.Sprintf(statPattern, "total", prepareValue(total)))
+func (m *Metrics) phases() (phases []phase) {
+	for _, p := range []phase{
+		{"handshake", m.handshake},
+		{"read", m.read},
+		{"handle", m.handle},
+		{"write", m.write},
+	} {
+		if p.dur >= 0 {
+			phases = append(phases, p)
+		}
 	}
 
-	line = fmt.Sprintf("%s: %s", m.topic, strings.Join(m.stat, ", "))
-
-	return line
+	return
 }
 
-func prepareValue(dur time.Duration) (size string) {
-	const valuePattern = "%d %ss"
-
-	ns := dur.Nanoseconds()
-	if ns < 1000 {
-		return fmt.Sprintf(valuePattern, ns, "n")
+func (m *Metrics) fields() (fields []Field) {
+	fields = []Field{
+		{Key: "topic", Value: m.topic},
+		{Key: "addr", Value: m.addr},
 	}
 
-	mcs := dur.Microseconds()
-	if mcs < 1000 {
-		return fmt.Sprintf(valuePattern, mcs, "µ")
+	var total time.Duration
+	for _, p := range m.phases() {
+		fields = append(fields, Field{Key: p.name, Value: p.dur})
+		total += p.dur
 	}
 
-	ms := dur.Milliseconds()
-	if ms < 1000 {
-		return fmt.Sprintf(valuePattern, ms, "m")
+	fields = append(fields, Field{Key: "total", Value: total})
+
+	if m.err != nil {
+		fields = append(fields, Field{Key: "error", Value: m.err})
 	}
 
-	return fmt.Sprintf("%.2f s", dur.Seconds())
+	return
 }
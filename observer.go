@@ -0,0 +1,61 @@
+package p2p
+
+import "time"
+
+// RequestStats describes a finished request.
+// Durations of phases that didn't happen are zero.
+type RequestStats struct {
+	Role  string
+	Topic string
+	Route string
+	Addr  string
+
+	Handshake time.Duration
+	Read      time.Duration
+	Handle    time.Duration
+	Write     time.Duration
+
+	BytesIn  int64
+	BytesOut int64
+
+	Err error
+}
+
+func (stats RequestStats) Total() (total time.Duration) {
+	return stats.Handshake + stats.Read + stats.Handle + stats.Write
+}
+
+type Observer func(stats RequestStats)
+
+func (m *Metrics) stats(role string, err error) (stats RequestStats) {
+	if err == nil {
+		err = m.err
+	}
+
+	stats = RequestStats{
+		Role:  role,
+		Topic: m.topic,
+		Route: m.route,
+		Addr:  m.addr,
+
+		BytesIn:  m.bytesIn,
+		BytesOut: m.bytesOut,
+
+		Err: err,
+	}
+
+	for _, p := range m.phases() {
+		switch p.name {
+		case "handshake":
+			stats.Handshake = p.dur
+		case "read":
+			stats.Read = p.dur
+		case "handle":
+			stats.Handle = p.dur
+		case "write":
+			stats.Write = p.dur
+		}
+	}
+
+	return
+}
@@ -0,0 +1,149 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestObserver(t *testing.T) {
+	port := newTestPort(t)
+
+	server, err := NewServer(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	server.SetHandler("user.{id}", func(ctx context.Context, req Data) (res Data, err error) {
+		res.SetBytes([]byte("user"))
+
+		return
+	})
+
+	serverStats := make(chan RequestStats, 1)
+	server.SetObserver(func(stats RequestStats) {
+		serverStats <- stats
+	})
+
+	startTestServer(t, server)
+
+	client, err := NewClient(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	settings := NewClientSettings()
+	settings.SetRetry(1, 0)
+	client.SetSettings(settings)
+
+	var clientStats []RequestStats
+	client.SetObserver(func(stats RequestStats) {
+		clientStats = append(clientStats, stats)
+	})
+
+	_, err = client.Send("user.42", Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stats RequestStats
+	select {
+	case stats = <-serverStats:
+	case <-time.After(time.Second):
+		t.Fatal("server observer is not called")
+	}
+
+	if stats.Role != ServerRole || stats.Topic != "user.42" || stats.Route != "user.{id}" || stats.Err != nil ||
+		stats.Handshake <= 0 || stats.Handle <= 0 || stats.BytesIn <= 0 || stats.BytesOut <= 0 {
+		t.Fatalf("unexpected server stats %+v", stats)
+	}
+
+	if len(clientStats) != 1 {
+		t.Fatalf("unexpected client stats %+v", clientStats)
+	}
+
+	stats = clientStats[0]
+	if stats.Role != ClientRole || stats.Topic != "user.42" || stats.Addr == "" ||
+		stats.Handshake <= 0 || stats.Handle != 0 || stats.Total() <= stats.Handshake || stats.BytesIn <= 0 || stats.BytesOut <= 0 {
+		t.Fatalf("unexpected client stats %+v", stats)
+	}
+
+	_, err = client.Send("unknown", Data{})
+	if err == nil {
+		t.Fatal("error is expected")
+	}
+
+	select {
+	case stats = <-serverStats:
+	case <-time.After(time.Second):
+		t.Fatal("server observer is not called")
+	}
+
+	if !errors.Is(stats.Err, UnsupportedTopic) || stats.Route != unsupportedRoute || stats.Handshake != 0 {
+		t.Fatalf("unexpected server stats %+v", stats)
+	}
+}
+
+func TestObserverDecryptError(t *testing.T) {
+	server, err := NewServer(NewTCP("127.0.0.1", newTestPort(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	server.SetHandler("ping", func(ctx context.Context, req Data) (res Data, err error) {
+		return
+	})
+
+	serverStats := make(chan RequestStats, 2)
+	server.SetObserver(func(stats RequestStats) {
+		serverStats <- stats
+	})
+
+	startTestServer(t, server)
+
+	client, err := NewClient(newTestTCP(t, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	settings := NewClientSettings()
+	settings.SetRetry(1, 0)
+	client.SetSettings(settings)
+
+	_, err = client.Send("ping", Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-serverStats
+
+	ck, err := NewCipherKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the server can't decrypt a request of a wrong key
+	client.tcp.cipherKey, err = newSessionKey(ck)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Send("ping", Data{})
+	if err == nil {
+		t.Fatal("error is expected")
+	}
+
+	select {
+	case stats := <-serverStats:
+		if stats.Err == nil {
+			t.Fatal("decrypt error is not observed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server observer is not called")
+	}
+}
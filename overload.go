@@ -0,0 +1,244 @@
+package p2p
+
+import "sync"
+
+const (
+	// maxShedding limits connections that are rejected at the same time,
+	// others are closed without an answer.
+	maxShedding = 64
+	// maxWaiting limits connections that wait for a place in a full accept queue,
+	// others are rejected at once.
+	maxWaiting = 64
+)
+
+// semaphore is unlimited when it's nil.
+type semaphore chan struct{}
+
+func newSemaphore(limit int) (sem semaphore) {
+	if limit <= 0 {
+		return nil
+	}
+
+	return make(semaphore, limit)
+}
+
+func (sem semaphore) acquire() (ok bool) {
+	if sem == nil {
+		return true
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (sem semaphore) release() {
+	if sem != nil {
+		<-sem
+	}
+}
+
+// gate limits handlers that run at the same time: all of them and handlers of every topic pattern.
+// A semaphore is replaced when its limit is changed.
+type gate struct {
+	mx       sync.Mutex
+	handlers semaphore
+	topics   map[string]semaphore
+}
+
+func newGate() (g *gate) {
+	return &gate{
+		mx:     sync.Mutex{},
+		topics: map[string]semaphore{},
+	}
+}
+
+func (g *gate) acquire(pattern string, limits Concurrency) (release func(), ok bool) {
+	g.mx.Lock()
+
+	handlers := g.handlers
+	if cap(handlers) != limits.handlers {
+		handlers = newSemaphore(limits.handlers)
+		g.handlers = handlers
+	}
+
+	limit := limits.topicHandlers(pattern)
+
+	topic := g.topics[pattern]
+	if cap(topic) != limit {
+		topic = newSemaphore(limit)
+		if topic == nil {
+			delete(g.topics, pattern)
+		} else {
+			g.topics[pattern] = topic
+		}
+	}
+
+	g.mx.Unlock()
+
+	if !handlers.acquire() {
+		return
+	}
+
+	if !topic.acquire() {
+		handlers.release()
+
+		return
+	}
+
+	release = func() {
+		topic.release()
+		handlers.release()
+	}
+	ok = true
+
+	return
+}
+
+// admit checks rate limits of the peer and the topic pattern and takes a handler slot.
+func (s *Server) admit(host, pattern string, settings ServerSettings) (release func(), err error) {
+	// an overloaded server still answers health checks
+	if pattern == HealthTopic {
+		return func() {}, nil
+	}
+
+	retryAfter, ok := s.rates.allow(host, pattern, settings.RateLimits)
+	if !ok {
+		err = &RateLimitError{
+			RetryAfter: retryAfter,
+		}
+
+		return
+	}
+
+	release, ok = s.gate.acquire(pattern, settings.Concurrency)
+	if !ok {
+		err = Overloaded
+	}
+
+	return
+}
+
+// shed reads the first package of a connection to answer in its wire format and rejects it with Overloaded error.
+func (s *Server) shed(conn *Conn, shedding semaphore) {
+	defer s.conns.Done()
+	defer shedding.release()
+
+	hooks := s.getHooks()
+	hooks.connOpened(conn.info)
+
+	defer func() {
+		err := conn.Close()
+		if err != nil {
+			s.logger.Error(err.Error())
+		}
+
+		hooks.connClosed(conn.info, Overloaded)
+	}()
+
+	s.logger.Warn("connection is rejected", Field{Key: "addr", Value: conn.info.RemoteAddr}, Field{Key: "error", Value: Overloaded})
+
+	var p Package
+	err := conn.ReadPackage(&p)
+	if err != nil {
+		return
+	}
+
+	if p.Type == Handshake {
+		err = conn.setPayload(&p, &RejectedError{
+			Reason:     Overloaded.Error(),
+			MinVersion: MinProtocolVersion,
+			MaxVersion: ProtocolVersion,
+			Overloaded: true,
+		})
+	} else {
+		err = conn.setPayload(&p, newRemoteError(Overloaded))
+	}
+
+	if err == nil {
+		p.Type = Error
+		err = conn.WritePackage(p)
+	}
+
+	if err != nil {
+		s.logger.Error(err.Error())
+	}
+}
+
+// pool is a pool of workers that process queued connections, its size follows max conns settings.
+type pool struct {
+	server *Server
+	queue  chan *Conn
+	quit   chan struct{}
+	stop   chan struct{}
+	size   int
+
+	// waiting limits connections that wait for the queue
+	waiting semaphore
+	waiters sync.WaitGroup
+}
+
+func (s *Server) newPool(queue int) (p *pool) {
+	return &pool{
+		server: s,
+		queue:  make(chan *Conn, queue),
+		quit:   make(chan struct{}),
+		stop:   make(chan struct{}),
+
+		waiting: newSemaphore(maxWaiting),
+	}
+}
+
+// resize starts or stops workers, queued connections are processed
+// without workers when the pool becomes unlimited.
+func (p *pool) resize(size int) {
+	for ; p.size < size; p.size++ {
+		go p.work()
+	}
+
+	for ; p.size > size; p.size-- {
+		go func() {
+			select {
+			case p.quit <- struct{}{}:
+			case <-p.stop:
+			}
+		}()
+	}
+
+	if size > 0 {
+		return
+	}
+
+	for {
+		select {
+		case conn := <-p.queue:
+			go p.server.processConn(conn, p.server.getSettings())
+		default:
+			return
+		}
+	}
+}
+
+func (p *pool) work() {
+	for {
+		select {
+		case conn, ok := <-p.queue:
+			if !ok {
+				return
+			}
+
+			p.server.processConn(conn, p.server.getSettings())
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+func (p *pool) close() {
+	close(p.stop)
+	p.waiters.Wait()
+	close(p.queue)
+}
@@ -0,0 +1,229 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newOverloadTest(t *testing.T, settings *ServerSettings) (server *Server, entered, unblock chan struct{}) {
+	port := newTestPort(t)
+
+	server, err := NewServer(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	settings.SetConnTimeout(time.Second)
+	settings.SetHandleTimeout(time.Second)
+	err = server.SetSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entered = make(chan struct{}, 1)
+	unblock = make(chan struct{})
+
+	server.SetHandler("slow", func(ctx context.Context, req Data) (res Data, err error) {
+		entered <- struct{}{}
+		<-unblock
+
+		return
+	})
+
+	server.SetHandler("fast", func(ctx context.Context, req Data) (res Data, err error) {
+		return
+	})
+
+	startTestServer(t, server)
+
+	return
+}
+
+func newOverloadClient(t *testing.T, server *Server) (client *Client) {
+	client, err := NewClient(newTestTCP(t, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	settings := NewClientSettings()
+	settings.SetConnTimeout(time.Second)
+	settings.SetRetry(1, 0)
+	client.SetSettings(settings)
+
+	return
+}
+
+func blockHandler(t *testing.T, server *Server, entered chan struct{}) (done chan error) {
+	done = make(chan error, 1)
+
+	go func() {
+		_, err := newOverloadClient(t, server).Send("slow", Data{})
+		done <- err
+	}()
+
+	select {
+	case <-entered:
+	case <-time.After(time.Second):
+		t.Fatal("handler is not called")
+	}
+
+	return
+}
+
+func TestMaxHandlers(t *testing.T) {
+	settings := NewServerSettings()
+	settings.SetMaxHandlers(1)
+
+	server, entered, unblock := newOverloadTest(t, settings)
+	done := blockHandler(t, server, entered)
+
+	client := newOverloadClient(t, server)
+
+	_, err := client.Send("fast", Data{})
+	if !errors.Is(err, Overloaded) {
+		t.Fatalf("overloaded error is expected, got %v", err)
+	}
+
+	close(unblock)
+
+	err = <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Send("fast", Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHealthOverloaded(t *testing.T) {
+	settings := NewServerSettings()
+	settings.SetMaxHandlers(1)
+	settings.SetPeerRateLimit(1, 1)
+
+	server, entered, unblock := newOverloadTest(t, settings)
+	defer close(unblock)
+
+	blockHandler(t, server, entered)
+
+	client := newOverloadClient(t, server)
+
+	for i := 0; i < 3; i++ {
+		status, err := client.Health(context.Background())
+		if err != nil || status != StatusServing {
+			t.Fatalf("unexpected status %s (%v)", status, err)
+		}
+	}
+}
+
+func TestTopicMaxHandlers(t *testing.T) {
+	settings := NewServerSettings()
+	settings.SetTopicMaxHandlers("slow", 1)
+
+	server, entered, unblock := newOverloadTest(t, settings)
+	defer close(unblock)
+
+	blockHandler(t, server, entered)
+
+	client := newOverloadClient(t, server)
+
+	_, err := client.Send("fast", Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Send("slow", Data{})
+	if !errors.Is(err, Overloaded) {
+		t.Fatalf("overloaded error is expected, got %v", err)
+	}
+}
+
+func TestMaxConns(t *testing.T) {
+	settings := NewServerSettings()
+	settings.SetMaxConns(1)
+	settings.SetAcceptQueue(0, 100*time.Millisecond)
+
+	server, entered, unblock := newOverloadTest(t, settings)
+
+	closed := make(chan error, 4)
+	server.SetHooks(Hooks{
+		ConnClosed: func(info ConnInfo, reason error) {
+			closed <- reason
+		},
+	})
+
+	done := blockHandler(t, server, entered)
+
+	_, err := newOverloadClient(t, server).Send("fast", Data{})
+	if !errors.Is(err, Overloaded) {
+		t.Fatalf("overloaded error is expected, got %v", err)
+	}
+
+	err = nil
+
+	// the probe of startTestServer can be closed after hooks are set
+	for !errors.Is(err, Overloaded) {
+		select {
+		case err = <-closed:
+		case <-time.After(time.Second):
+			t.Fatal("connection isn't closed with overloaded reason")
+		}
+	}
+
+	close(unblock)
+
+	err = <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = newOverloadClient(t, server).Send("fast", Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcceptTimeoutInBackground(t *testing.T) {
+	settings := NewServerSettings()
+	settings.SetMaxConns(1)
+	settings.SetAcceptQueue(0, 300*time.Millisecond)
+
+	server, entered, unblock := newOverloadTest(t, settings)
+	defer close(unblock)
+
+	blockHandler(t, server, entered)
+
+	// connections wait for the queue at the same time, not one by one
+	const clients = 3
+
+	var list []*Client
+	for i := 0; i < clients; i++ {
+		list = append(list, newOverloadClient(t, server))
+	}
+
+	errs := make(chan error, clients)
+	tm := time.Now()
+
+	for _, client := range list {
+		go func(client *Client) {
+			_, err := client.Send("fast", Data{})
+			errs <- err
+		}(client)
+	}
+
+	for i := 0; i < clients; i++ {
+		err := <-errs
+		if !errors.Is(err, Overloaded) {
+			t.Fatalf("overloaded error is expected, got %v", err)
+		}
+	}
+
+	if dur := time.Since(tm); dur > 750*time.Millisecond {
+		t.Fatalf("connections wait one by one: %v", dur)
+	}
+}
@@ -2,6 +2,7 @@ package p2p
 
 type Package struct {
 	Type PackageType
+	ID   uint64
 	Data
 }
 
@@ -11,4 +12,6 @@ const (
 	Handshake PackageType = iota
 	Exchange
 	Error
+	Link
+	Reply
 )
@@ -0,0 +1,418 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+type Peer struct {
+	conn      *Conn
+	cipherKey *sessionKey
+	logger    fieldLogger
+
+	ctx         context.Context
+	compression Compression
+	owner       *Router
+
+	wmx sync.Mutex
+
+	seq uint64
+
+	router *Router
+
+	// admit checks limits of handlers of server peers.
+	admit func(pattern string) (release func(), err error)
+
+	mx      sync.RWMutex
+	pending map[uint64]chan Package
+
+	done chan struct{}
+	once sync.Once
+}
+
+type LinkHandler func(peer *Peer)
+
+func newPeer(conn *Conn, sk *sessionKey, logger fieldLogger, ctx context.Context, compression Compression, owner *Router) (p *Peer) {
+	return &Peer{
+		conn:      conn,
+		cipherKey: sk,
+		logger:    logger.with(Field{Key: "addr", Value: conn.RemoteAddr().String()}),
+
+		ctx:         ctx,
+		compression: compression,
+		owner:       owner,
+
+		router: NewRouter(),
+
+		mx:      sync.RWMutex{},
+		pending: map[uint64]chan Package{},
+
+		done: make(chan struct{}),
+	}
+}
+
+func (p *Peer) SetHandler(topic string, handler Handler) {
+	p.router.SetHandler(topic, handler)
+}
+
+func (p *Peer) setSchema(topic string, schema Schema) {
+	p.router.setSchema(topic, schema)
+}
+
+func (p *Peer) RemoveHandler(topic string) (ok bool) {
+	return p.router.RemoveHandler(topic)
+}
+
+func (p *Peer) Handlers() (topics []string) {
+	return p.router.Handlers()
+}
+
+func (p *Peer) Group(prefix string) (g *Group) {
+	return p.router.Group(prefix)
+}
+
+func (p *Peer) RemoteAddr() (addr net.Addr) {
+	return p.conn.RemoteAddr()
+}
+
+func (p *Peer) Done() (done <-chan struct{}) {
+	return p.done
+}
+
+func (p *Peer) Close() (err error) {
+	p.shutdown()
+	err = p.conn.Close()
+
+	return
+}
+
+func (p *Peer) Send(topic string, req Data) (res Data, err error) {
+	res, _, err = p.SendWithMetadata(topic, req, nil)
+
+	return
+}
+
+// SendWithMetadata waits for a response for the idle timeout as a link doesn't limit reading by it.
+func (p *Peer) SendWithMetadata(topic string, req Data, md Metadata) (res Data, resMD Metadata, err error) {
+	ctx := context.Background()
+	if idle := p.conn.limiter.idle; idle > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, idle)
+		defer cancel()
+	}
+
+	return p.SendContext(ctx, topic, req, md)
+}
+
+// SendContext sends a request and doesn't wait for a response after ctx is done.
+func (p *Peer) SendContext(ctx context.Context, topic string, req Data, md Metadata) (res Data, resMD Metadata, err error) {
+	msg := Message{
+		Topic:    topic,
+		Metadata: md,
+	}
+	msg.setData(req)
+
+	err = msg.compress(p.compression)
+	if err != nil {
+		p.logger.Error(err.Error())
+
+		return
+	}
+
+	var cm CryptMessage
+	cm, err = p.conn.encodeMessage(msg, p.cipherKey)
+	if err != nil {
+		p.logger.Error(err.Error())
+
+		return
+	}
+
+	pkg := Package{
+		Type: Exchange,
+		ID:   atomic.AddUint64(&p.seq, 1),
+	}
+
+	err = p.conn.setPayload(&pkg, cm)
+	if err != nil {
+		p.logger.Error(err.Error())
+
+		return
+	}
+
+	ch := make(chan Package, 1)
+
+	p.mx.Lock()
+	p.pending[pkg.ID] = ch
+	p.mx.Unlock()
+
+	defer func() {
+		p.mx.Lock()
+		delete(p.pending, pkg.ID)
+		p.mx.Unlock()
+	}()
+
+	err = p.write(pkg)
+	if err != nil {
+		p.logger.Error(err.Error())
+
+		return
+	}
+
+	select {
+	case pkg = <-ch:
+	case <-p.done:
+		err = LinkClosedError
+
+		return
+	case <-ctx.Done():
+		err = ctx.Err()
+
+		return
+	}
+
+	if pkg.Type == Error {
+		err = p.conn.packageError(pkg)
+
+		return
+	}
+
+	err = p.conn.getPayload(pkg, &cm)
+	if err != nil {
+		p.logger.Error(err.Error())
+
+		return
+	}
+
+	msg, err = p.conn.decodeMessage(cm, p.cipherKey)
+	if err != nil {
+		p.logger.Error(err.Error())
+
+		return
+	}
+
+	err = msg.decompress(p.conn.limiter.decompression)
+	if err != nil {
+		p.logger.Error(err.Error())
+
+		return
+	}
+
+	if msg.Error != nil {
+		err = msg.remoteError()
+
+		return
+	}
+
+	res = msg.data()
+	resMD = msg.Metadata
+
+	return
+}
+
+func (p *Peer) accept() (err error) {
+	err = p.write(Package{
+		Type: Link,
+	})
+
+	return
+}
+
+func (p *Peer) confirm() (err error) {
+	var pkg Package
+	err = p.conn.ReadPackage(&pkg)
+	if err != nil {
+		return
+	}
+
+	switch pkg.Type {
+	case Link:
+	case Error:
+		err = p.conn.packageError(pkg)
+	default:
+		err = UnsupportedPackage
+	}
+
+	return
+}
+
+func (p *Peer) serve() (err error) {
+	defer p.shutdown()
+
+	var pkg Package
+	for {
+		pkg = Package{}
+		err = p.conn.ReadPackage(&pkg)
+		if err != nil {
+			if errors.Is(err, io.EOF) || p.closed() {
+				err = nil
+			}
+
+			return
+		}
+
+		switch pkg.Type {
+		case Exchange:
+			go p.handle(pkg)
+		case Reply, Error:
+			p.mx.RLock()
+			ch, ok := p.pending[pkg.ID]
+			p.mx.RUnlock()
+
+			// a duplicate reply doesn't block reading
+			if ok {
+				select {
+				case ch <- pkg:
+				default:
+					p.logger.Warn("duplicate reply", Field{Key: "id", Value: pkg.ID})
+				}
+			}
+		default:
+			p.logger.Warn(UnsupportedPackage.Error())
+		}
+	}
+}
+
+func (p *Peer) handle(in Package) {
+	var cm CryptMessage
+	err := p.conn.getPayload(in, &cm)
+	if err != nil {
+		p.logger.Error(err.Error())
+
+		return
+	}
+
+	var (
+		msg         Message
+		compression Compression
+	)
+	msg, err = p.conn.decodeMessage(cm, p.cipherKey)
+	if err != nil {
+		p.logger.Warn(err.Error())
+
+		err = p.write(Package{
+			Type: Error,
+			ID:   in.ID,
+		})
+		if err != nil {
+			p.logger.Error(err.Error())
+		}
+
+		return
+	}
+
+	var (
+		res    Data
+		holder *metadataHolder
+	)
+
+	compression = p.compression.reply(msg.Compression)
+
+	pattern, handler, params, ok := p.handler(msg.Topic)
+
+	release := func() {}
+
+	err = msg.decompress(p.conn.limiter.decompression)
+	if err != nil {
+		msg.Compression = ""
+		compression = Compression{}
+	} else if ok && p.admit != nil {
+		release, err = p.admit(pattern)
+	}
+
+	switch {
+	case ok && err == nil:
+		ctx, cancel := p.conn.limiter.handleContext(p.ctx)
+		defer cancel()
+
+		ctx, holder = withMetadata(ctx, msg.Metadata)
+
+		res, err = handler(withParams(ctx, params), msg.data())
+		release()
+
+		if err != nil {
+			p.logger.Error("handler failed", Field{Key: "topic", Value: msg.Topic}, Field{Key: "error", Value: err})
+		}
+	case err != nil:
+		p.logger.Warn(err.Error(), Field{Key: "topic", Value: msg.Topic})
+	default:
+		err = UnsupportedTopic
+
+		p.logger.Warn(err.Error(), Field{Key: "topic", Value: msg.Topic})
+	}
+
+	msg.setData(res)
+	msg.Metadata = nil
+
+	if holder != nil {
+		msg.Metadata = holder.outgoing()
+	}
+
+	msg.setError(err)
+
+	err = msg.compress(compression)
+	if err != nil {
+		p.logger.Error(err.Error())
+
+		return
+	}
+
+	cm, err = p.conn.encodeMessage(msg, p.cipherKey)
+	if err != nil {
+		p.logger.Error(err.Error())
+
+		return
+	}
+
+	out := Package{
+		Type: Reply,
+		ID:   in.ID,
+	}
+
+	err = p.conn.setPayload(&out, cm)
+	if err != nil {
+		p.logger.Error(err.Error())
+
+		return
+	}
+
+	err = p.write(out)
+	if err != nil {
+		p.logger.Error(err.Error())
+	}
+}
+
+func (p *Peer) handler(topic string) (pattern string, handler Handler, params Params, ok bool) {
+	pattern, handler, params, ok = p.router.lookup(topic)
+	if !ok && p.owner != nil {
+		pattern, handler, params, ok = p.owner.lookup(topic)
+	}
+
+	return
+}
+
+func (p *Peer) write(pkg Package) (err error) {
+	p.wmx.Lock()
+	err = p.conn.WritePackage(pkg)
+	p.wmx.Unlock()
+
+	return
+}
+
+func (p *Peer) closed() (ok bool) {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Peer) shutdown() {
+	p.once.Do(func() {
+		close(p.done)
+	})
+}
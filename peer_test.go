@@ -0,0 +1,234 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) Info(string)  {}
+func (l nopLogger) Warn(string)  {}
+func (l nopLogger) Error(string) {}
+
+func newTestPort(t *testing.T) (port string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, port, err = net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = listener.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return
+}
+
+func startTestServer(t *testing.T, server *Server) {
+	go func() {
+		_ = server.Serve()
+	}()
+
+	for i := 0; i < 100; i++ {
+		conn, err := net.Dial("tcp", server.tcp.addr)
+		if err == nil {
+			_ = conn.Close()
+
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("server is not started")
+}
+
+// newTestTCP returns a client's TCP of the server, which doesn't share the server's cipher key.
+func newTestTCP(t *testing.T, server *Server) (tcp *TCP) {
+	host, port, err := net.SplitHostPort(server.tcp.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewTCP(host, port)
+}
+
+func TestPeer(t *testing.T) {
+	port := newTestPort(t)
+
+	server, err := NewServer(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	server.SetHandler("ping", func(ctx context.Context, req Data) (res Data, err error) {
+		res.SetBytes(append([]byte("pong "), req.GetBytes()...))
+
+		SetResponseMetadata(ctx, "trace-id", IncomingMetadata(ctx).Get("trace-id"))
+
+		return
+	})
+
+	peers := make(chan *Peer, 1)
+	server.SetLinkHandler(func(peer *Peer) {
+		peers <- peer
+	})
+
+	startTestServer(t, server)
+
+	client, err := NewClient(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	client.SetHandler("whoami", func(ctx context.Context, req Data) (res Data, err error) {
+		res.SetBytes([]byte("client"))
+
+		return
+	})
+
+	clientPeer, err := client.Link()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = clientPeer.Close()
+	}()
+
+	var (
+		req, res Data
+		md       Metadata
+	)
+	req.SetBytes([]byte("client"))
+	res, md, err = clientPeer.SendWithMetadata("ping", req, Metadata{"trace-id": "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.String() != "pong client" {
+		t.Fatalf("unexpected response %q", res.String())
+	}
+
+	if md.Get("trace-id") != "42" {
+		t.Fatalf("unexpected metadata %v", md)
+	}
+
+	var serverPeer *Peer
+	select {
+	case serverPeer = <-peers:
+	case <-time.After(time.Second):
+		t.Fatal("link handler is not called")
+	}
+
+	res, err = serverPeer.Send("whoami", Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.String() != "client" {
+		t.Fatalf("unexpected response %q", res.String())
+	}
+
+	_, err = serverPeer.Send("unknown", Data{})
+	if !errors.Is(err, UnsupportedTopic) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	err = clientPeer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-serverPeer.Done():
+	case <-time.After(time.Second):
+		t.Fatal("server peer is not closed")
+	}
+}
+
+func TestShutdownLinked(t *testing.T) {
+	server, err := NewServer(NewTCP("127.0.0.1", newTestPort(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	startTestServer(t, server)
+
+	client, err := NewClient(newTestTCP(t, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	peer, err := client.Link()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err = server.Shutdown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-peer.Done():
+	case <-time.After(time.Second):
+		t.Fatal("client peer is not closed")
+	}
+}
+
+func TestPeerSendContext(t *testing.T) {
+	server, err := NewServer(NewTCP("127.0.0.1", newTestPort(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	release := make(chan struct{})
+	defer close(release)
+
+	server.SetHandler("block", func(ctx context.Context, req Data) (res Data, err error) {
+		<-release
+
+		return
+	})
+
+	startTestServer(t, server)
+
+	client, err := NewClient(newTestTCP(t, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	peer, err := client.Link()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = peer.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, _, err = peer.SendContext(ctx, "block", Data{}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
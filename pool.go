@@ -0,0 +1,28 @@
+package p2p
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBuffer keeps rare large messages from pinning memory in the pool.
+const maxPooledBuffer = 64 << 10
+
+var buffers = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() (buf *bytes.Buffer) {
+	return buffers.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+
+	buf.Reset()
+	buffers.Put(buf)
+}
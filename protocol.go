@@ -0,0 +1,172 @@
+package p2p
+
+import (
+	"crypto/rsa"
+	"fmt"
+)
+
+const (
+	LegacyProtocolVersion uint16 = 1
+	MinProtocolVersion    uint16 = 1
+	ProtocolVersion       uint16 = 2
+)
+
+const CipherSuiteAES128GCM = "aes-128-gcm"
+
+const (
+	FeatureLink        = "link"
+	FeatureMetadata    = "metadata"
+	FeatureCompression = "compression"
+	FeatureRouting     = "routing"
+	FeatureStreaming   = "streaming"
+	FeatureGobStream   = "gob-stream"
+)
+
+type Capabilities struct {
+	Compressors  []string
+	CipherSuites []string
+	Features     []string
+}
+
+func localCapabilities() (cs Capabilities) {
+	return Capabilities{
+		Compressors:  Compressors(),
+		CipherSuites: []string{CipherSuiteAES128GCM},
+		Features:     []string{FeatureLink, FeatureMetadata, FeatureCompression, FeatureRouting, FeatureGobStream},
+	}
+}
+
+func (cs Capabilities) HasCompressor(name string) (ok bool) {
+	return contains(cs.Compressors, name)
+}
+
+func (cs Capabilities) HasFeature(name string) (ok bool) {
+	return contains(cs.Features, name)
+}
+
+func (cs Capabilities) intersect(remote Capabilities) (common Capabilities) {
+	return Capabilities{
+		Compressors:  intersect(remote.Compressors, cs.Compressors),
+		CipherSuites: intersect(remote.CipherSuites, cs.CipherSuites),
+		Features:     intersect(remote.Features, cs.Features),
+	}
+}
+
+type Session struct {
+	Version      uint16
+	CipherSuite  string
+	Capabilities Capabilities
+}
+
+func (session Session) compression(cmp Compression) (c Compression) {
+	c = cmp
+	if !session.Capabilities.HasCompressor(cmp.name) {
+		c.name = ""
+	}
+
+	return
+}
+
+type Hello struct {
+	Key          rsa.PublicKey
+	Version      uint16
+	MinVersion   uint16
+	Capabilities Capabilities
+}
+
+type Welcome struct {
+	Key          CryptCipherKey
+	Version      uint16
+	CipherSuite  string
+	Capabilities Capabilities
+}
+
+type RejectedError struct {
+	Reason     string
+	MinVersion uint16
+	MaxVersion uint16
+	Overloaded bool
+}
+
+func (e *RejectedError) Error() (str string) {
+	return fmt.Sprintf("handshake is rejected: %s (supported protocol versions %d-%d)",
+		e.Reason, e.MinVersion, e.MaxVersion)
+}
+
+func (e *RejectedError) Unwrap() (err error) {
+	if e.Overloaded {
+		return Overloaded
+	}
+
+	return nil
+}
+
+func newHello(pk PublicKey) (hello Hello) {
+	return Hello{
+		Key:          pk.Key,
+		Version:      ProtocolVersion,
+		MinVersion:   MinProtocolVersion,
+		Capabilities: localCapabilities(),
+	}
+}
+
+func negotiate(hello Hello) (session Session, err error) {
+	if hello.Version == 0 {
+		session = Session{
+			Version:     LegacyProtocolVersion,
+			CipherSuite: CipherSuiteAES128GCM,
+		}
+
+		return
+	}
+
+	session.Version = hello.Version
+	if session.Version > ProtocolVersion {
+		session.Version = ProtocolVersion
+	}
+
+	if session.Version < MinProtocolVersion || session.Version < hello.MinVersion {
+		err = &RejectedError{
+			Reason:     fmt.Sprintf("unsupported protocol versions %d-%d", hello.MinVersion, hello.Version),
+			MinVersion: MinProtocolVersion,
+			MaxVersion: ProtocolVersion,
+		}
+
+		return
+	}
+
+	session.Capabilities = localCapabilities().intersect(hello.Capabilities)
+	if len(session.Capabilities.CipherSuites) == 0 {
+		err = &RejectedError{
+			Reason:     "no common cipher suite",
+			MinVersion: MinProtocolVersion,
+			MaxVersion: ProtocolVersion,
+		}
+
+		return
+	}
+
+	session.CipherSuite = session.Capabilities.CipherSuites[0]
+
+	return
+}
+
+func contains(list []string, value string) (ok bool) {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return
+}
+
+func intersect(preferred, supported []string) (common []string) {
+	for _, item := range preferred {
+		if contains(supported, item) {
+			common = append(common, item)
+		}
+	}
+
+	return
+}
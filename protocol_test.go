@@ -0,0 +1,99 @@
+package p2p
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	session, err := negotiate(Hello{})
+	if err != nil || session.Version != LegacyProtocolVersion {
+		t.Fatalf("unexpected legacy session %v (%v)", session, err)
+	}
+
+	hello := Hello{
+		Version:    ProtocolVersion + 1,
+		MinVersion: MinProtocolVersion,
+		Capabilities: Capabilities{
+			Compressors:  []string{"unknown", GzipCompressorName},
+			CipherSuites: []string{"unknown", CipherSuiteAES128GCM},
+			Features:     []string{FeatureStreaming, FeatureLink},
+		},
+	}
+
+	session, err = negotiate(hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if session.Version != ProtocolVersion || session.CipherSuite != CipherSuiteAES128GCM ||
+		!session.Capabilities.HasCompressor(GzipCompressorName) || session.Capabilities.HasCompressor("unknown") ||
+		!session.Capabilities.HasFeature(FeatureLink) || session.Capabilities.HasFeature(FeatureStreaming) {
+		t.Fatalf("unexpected session %v", session)
+	}
+
+	hello.MinVersion = ProtocolVersion + 1
+
+	var re *RejectedError
+	_, err = negotiate(hello)
+	if !errors.As(err, &re) || re.MaxVersion != ProtocolVersion {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	hello.MinVersion = MinProtocolVersion
+	hello.Capabilities.CipherSuites = []string{"unknown"}
+
+	_, err = negotiate(hello)
+	if !errors.As(err, &re) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestHelloCompatibility(t *testing.T) {
+	rsa, err := NewRSA()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var d Data
+	err = d.SetGob(newHello(rsa.PublicKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pk PublicKey
+	err = d.GetGob(&pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pk.Key.N.Cmp(rsa.PublicKey().Key.N) != 0 {
+		t.Fatal("legacy public key is not decoded from hello")
+	}
+
+	err = d.SetGob(rsa.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hello Hello
+	err = d.GetGob(&hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hello.Version != 0 || hello.Key.N.Cmp(rsa.PublicKey().Key.N) != 0 {
+		t.Fatalf("unexpected hello from legacy public key %v", hello.Version)
+	}
+
+	err = d.SetGob(CryptCipherKey("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var welcome Welcome
+	err = d.GetGob(&welcome)
+	if err == nil {
+		t.Fatal("legacy cipher key is decoded as welcome")
+	}
+}
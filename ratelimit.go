@@ -0,0 +1,245 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryAfterKey is a response metadata key of a rate limited request,
+// it holds milliseconds to wait before the next request.
+const RetryAfterKey = "retry-after"
+
+// cleanInterval is how often buckets of peers that are idle long enough to be full are removed.
+const cleanInterval = time.Minute
+
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() (str string) {
+	return fmt.Sprintf("%s: retry after %s", RateLimited, e.RetryAfter)
+}
+
+func (e *RateLimitError) Unwrap() (err error) {
+	return RateLimited
+}
+
+func retryAfter(err error) (dur time.Duration) {
+	var rle *RateLimitError
+	if errors.As(err, &rle) {
+		dur = rle.RetryAfter
+	}
+
+	return
+}
+
+// setError sends retry-after of a rate limit error in response metadata
+// because only a text of the error is sent.
+func (msg *Message) setError(err error) {
+	msg.Error = err
+
+	var rle *RateLimitError
+	if !errors.As(err, &rle) {
+		return
+	}
+
+	msg.Error = RateLimited
+
+	if msg.Metadata == nil {
+		msg.Metadata = Metadata{}
+	}
+
+	ms := (rle.RetryAfter + time.Millisecond - 1) / time.Millisecond
+	msg.Metadata.Set(RetryAfterKey, strconv.FormatInt(int64(ms), 10))
+}
+
+// remoteError restores a rate limit error from response metadata.
+func (msg Message) remoteError() (err error) {
+	err = msg.Error
+	if err == nil || !errors.Is(err, RateLimited) {
+		return
+	}
+
+	ms, perr := strconv.ParseInt(msg.Metadata.Get(RetryAfterKey), 10, 64)
+	if perr != nil {
+		return
+	}
+
+	return &RateLimitError{
+		RetryAfter: time.Duration(ms) * time.Millisecond,
+	}
+}
+
+// rateLimit is unlimited when its limit isn't positive.
+type rateLimit struct {
+	limit float64
+	burst int
+}
+
+func newRateLimit(limit float64, burst uint) (rl rateLimit) {
+	if burst == 0 {
+		burst = 1
+	}
+
+	return rateLimit{
+		limit: limit,
+		burst: int(burst),
+	}
+}
+
+type bucket struct {
+	rate   rateLimit
+	tokens float64
+	tm     time.Time
+}
+
+func newBucket(rate rateLimit, now time.Time) (b *bucket) {
+	return &bucket{
+		rate:   rate,
+		tokens: float64(rate.burst),
+		tm:     now,
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.tm).Seconds() * b.rate.limit
+	if b.tokens > float64(b.rate.burst) {
+		b.tokens = float64(b.rate.burst)
+	}
+
+	b.tm = now
+}
+
+// wait returns how long to wait for a token.
+func (b *bucket) wait() (dur time.Duration) {
+	if b.tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) / b.rate.limit * float64(time.Second))
+}
+
+func (b *bucket) full() (ok bool) {
+	return b.tokens >= float64(b.rate.burst)
+}
+
+// rateLimiter keeps token buckets of peers (or of all requests on a client) and of topics.
+// A bucket is replaced when its limit is changed.
+type rateLimiter struct {
+	mx      sync.Mutex
+	peers   map[string]*bucket
+	topics  map[string]*bucket
+	cleaned time.Time
+}
+
+func newRateLimiter() (rl *rateLimiter) {
+	return &rateLimiter{
+		mx:      sync.Mutex{},
+		peers:   map[string]*bucket{},
+		topics:  map[string]*bucket{},
+		cleaned: time.Now(),
+	}
+}
+
+func (rl *rateLimiter) buckets(peer, topic string, limits RateLimits, now time.Time) (buckets []*bucket) {
+	if now.Sub(rl.cleaned) >= cleanInterval {
+		rl.clean(now)
+	}
+
+	for _, b := range []*bucket{
+		rl.bucket(rl.peers, peer, limits.requests, now),
+		rl.bucket(rl.topics, topic, limits.topics[topic], now),
+	} {
+		if b != nil {
+			buckets = append(buckets, b)
+		}
+	}
+
+	return
+}
+
+func (rl *rateLimiter) bucket(buckets map[string]*bucket, key string, rate rateLimit, now time.Time) (b *bucket) {
+	if rate.limit <= 0 {
+		delete(buckets, key)
+
+		return nil
+	}
+
+	b, ok := buckets[key]
+	if !ok || b.rate != rate {
+		b = newBucket(rate, now)
+		buckets[key] = b
+	}
+
+	b.refill(now)
+
+	return
+}
+
+func (rl *rateLimiter) clean(now time.Time) {
+	rl.cleaned = now
+
+	for key, b := range rl.peers {
+		b.refill(now)
+		if b.full() {
+			delete(rl.peers, key)
+		}
+	}
+}
+
+// allow takes tokens of the peer and the topic if both have them,
+// otherwise it returns how long to wait for them.
+func (rl *rateLimiter) allow(peer, topic string, limits RateLimits) (retryAfter time.Duration, ok bool) {
+	rl.mx.Lock()
+	defer rl.mx.Unlock()
+
+	buckets := rl.buckets(peer, topic, limits, time.Now())
+	for _, b := range buckets {
+		wait := b.wait()
+		if wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	if retryAfter > 0 {
+		return
+	}
+
+	for _, b := range buckets {
+		b.tokens--
+	}
+
+	return 0, true
+}
+
+// reserve takes tokens of the topic in advance and returns how long to wait until they are available.
+func (rl *rateLimiter) reserve(topic string, limits RateLimits) (wait time.Duration) {
+	rl.mx.Lock()
+	defer rl.mx.Unlock()
+
+	for _, b := range rl.buckets("", topic, limits, time.Now()) {
+		dur := b.wait()
+		if dur > wait {
+			wait = dur
+		}
+
+		b.tokens--
+	}
+
+	return
+}
+
+func remoteHost(conn net.Conn) (host string) {
+	host = conn.RemoteAddr().String()
+
+	h, _, err := net.SplitHostPort(host)
+	if err == nil {
+		host = h
+	}
+
+	return
+}
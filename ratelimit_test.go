@@ -0,0 +1,246 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limits := RateLimits{
+		requests: newRateLimit(1, 2),
+	}
+	limits.setTopic("report", newRateLimit(1, 3))
+
+	rl := newRateLimiter()
+
+	for i := 0; i < 2; i++ {
+		_, ok := rl.allow("a", "report", limits)
+		if !ok {
+			t.Fatalf("request %d is expected to be allowed", i)
+		}
+	}
+
+	retryAfter, ok := rl.allow("a", "report", limits)
+	if ok || retryAfter <= 0 || retryAfter > time.Second {
+		t.Fatalf("unexpected result %v %v", retryAfter, ok)
+	}
+
+	_, ok = rl.allow("b", "report", limits)
+	if !ok {
+		t.Fatal("request of another peer is expected to be allowed")
+	}
+
+	_, ok = rl.allow("c", "report", limits)
+	if ok {
+		t.Fatal("topic limit is expected to be exceeded")
+	}
+
+	_, ok = rl.allow("c", "other", limits)
+	if !ok {
+		t.Fatal("request of another topic is expected to be allowed")
+	}
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	limits := RateLimits{
+		requests: newRateLimit(10, 1),
+	}
+
+	rl := newRateLimiter()
+
+	if wait := rl.reserve("topic", limits); wait != 0 {
+		t.Fatalf("unexpected wait %v", wait)
+	}
+
+	wait := rl.reserve("topic", limits)
+	if wait <= 0 || wait > 100*time.Millisecond {
+		t.Fatalf("unexpected wait %v", wait)
+	}
+
+	next := rl.reserve("topic", limits)
+	if next <= wait || next > 200*time.Millisecond {
+		t.Fatalf("unexpected wait %v after %v", next, wait)
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	for _, wire := range []string{GobWireName, FrameWireName} {
+		t.Run(wire, func(t *testing.T) {
+			port := newTestPort(t)
+
+			server, err := NewServer(NewTCP("127.0.0.1", port))
+			if err != nil {
+				t.Fatal(err)
+			}
+			server.SetLogger(nopLogger{})
+
+			settings := NewServerSettings()
+			settings.SetTopicRateLimit("report.{id}", 10, 1)
+			err = server.SetSettings(settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			server.SetHandler("report.{id}", func(ctx context.Context, req Data) (res Data, err error) {
+				return
+			})
+
+			startTestServer(t, server)
+
+			client, err := NewClient(NewTCP("127.0.0.1", port))
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.SetLogger(nopLogger{})
+
+			clientSettings := NewClientSettings()
+			clientSettings.SetWireCodec(wire)
+			clientSettings.SetRetry(1, 0)
+			client.SetSettings(clientSettings)
+
+			_, err = client.Send("report.1", Data{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = client.Send("report.2", Data{})
+			if !errors.Is(err, RateLimited) {
+				t.Fatalf("rate limit error is expected, got %v", err)
+			}
+
+			var rle *RateLimitError
+			if !errors.As(err, &rle) || rle.RetryAfter <= 0 || rle.RetryAfter > 100*time.Millisecond {
+				t.Fatalf("unexpected retry after of %v", err)
+			}
+
+			// the client waits retry-after before the second try
+			clientSettings.SetRetry(2, 0)
+
+			_, err = client.Send("report.3", Data{})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRetryAfterDeadline(t *testing.T) {
+	server, err := NewServer(NewTCP("127.0.0.1", newTestPort(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	settings := NewServerSettings()
+	settings.SetTopicRateLimit("report", 1, 1)
+	err = server.SetSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.SetHandler("report", func(ctx context.Context, req Data) (res Data, err error) {
+		return
+	})
+
+	startTestServer(t, server)
+
+	client, err := NewClient(newTestTCP(t, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	clientSettings := NewClientSettings()
+	clientSettings.SetRetry(2, 0)
+	client.SetSettings(clientSettings)
+
+	_, err = client.Send("report", Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// retry-after is longer than the deadline, so the client doesn't wait for it
+	tm := time.Now()
+
+	_, _, err = client.SendContext(ctx, "report", Data{}, nil)
+	if !errors.Is(err, RateLimited) {
+		t.Fatalf("rate limit error is expected, got %v", err)
+	}
+
+	if dur := time.Since(tm); dur > 100*time.Millisecond {
+		t.Fatalf("client waits retry-after: %v", dur)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	tm = time.Now()
+
+	_, _, err = client.SendContext(ctx, "report", Data{}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancel error is expected, got %v", err)
+	}
+
+	if dur := time.Since(tm); dur > 500*time.Millisecond {
+		t.Fatalf("client waits retry-after after cancel: %v", dur)
+	}
+}
+
+func TestClientRateLimit(t *testing.T) {
+	port := newTestPort(t)
+
+	server, err := NewServer(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	server.SetHandler("ping", func(ctx context.Context, req Data) (res Data, err error) {
+		return
+	})
+
+	startTestServer(t, server)
+
+	client, err := NewClient(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	settings := NewClientSettings()
+	settings.SetRetry(1, 0)
+	settings.SetRateLimit(20, 1)
+	client.SetSettings(settings)
+
+	tm := time.Now()
+
+	for i := 0; i < 3; i++ {
+		_, err = client.Send("ping", Data{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if dur := time.Since(tm); dur < 90*time.Millisecond {
+		t.Fatalf("requests are sent too fast: %v", dur)
+	}
+
+	settings.SetRateLimit(1, 1)
+	_, err = client.Send("ping", Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, _, err = client.SendContext(ctx, "ping", Data{}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("deadline error is expected, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package p2p
+
+import (
+	"context"
+	"runtime/debug"
+)
+
+const (
+	ModulePath      = "github.com/leprosus/golang-p2p"
+	ReflectionTopic = "p2p.reflection"
+)
+
+type ServerInfo struct {
+	Version      string      `json:"version"`
+	Protocol     uint16      `json:"protocol"`
+	CipherSuites []string    `json:"cipher_suites"`
+	Capabilities []string    `json:"capabilities"`
+	Codecs       []string    `json:"codecs"`
+	Compressors  []string    `json:"compressors"`
+	Topics       []TopicInfo `json:"topics"`
+}
+
+type TopicInfo struct {
+	Topic  string  `json:"topic"`
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+func (s *Server) EnableReflection() {
+	s.SetHandler(ReflectionTopic, func(ctx context.Context, req Data) (res Data, err error) {
+		err = res.SetJson(s.Info())
+
+		return
+	})
+}
+
+func (s *Server) Info() (info ServerInfo) {
+	caps := localCapabilities()
+
+	info = ServerInfo{
+		Version:      Version(),
+		Protocol:     ProtocolVersion,
+		CipherSuites: caps.CipherSuites,
+		Capabilities: caps.Features,
+		Codecs:       Codecs(),
+		Compressors:  Compressors(),
+	}
+
+	for _, topic := range s.router.Handlers() {
+		ti := TopicInfo{
+			Topic: topic,
+		}
+
+		schema, ok := s.router.Schema(topic)
+		if ok {
+			ti.Schema = &schema
+		}
+
+		info.Topics = append(info.Topics, ti)
+	}
+
+	return
+}
+
+func (c *Client) Reflect() (info ServerInfo, err error) {
+	return c.ReflectContext(context.Background())
+}
+
+func (c *Client) ReflectContext(ctx context.Context) (info ServerInfo, err error) {
+	var res Data
+	res, _, err = c.SendContext(ctx, ReflectionTopic, Data{}, nil)
+	if err != nil {
+		return
+	}
+
+	err = res.Decode(&info)
+
+	return
+}
+
+func Version() (version string) {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+
+	if bi.Main.Path == ModulePath {
+		return bi.Main.Version
+	}
+
+	for _, dep := range bi.Deps {
+		if dep.Path == ModulePath {
+			return dep.Version
+		}
+	}
+
+	return
+}
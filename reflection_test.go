@@ -0,0 +1,59 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReflection(t *testing.T) {
+	port := newTestPort(t)
+
+	server, err := NewServer(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+	server.EnableReflection()
+
+	HandleTyped(server.Group("shop"), "buy", func(ctx context.Context, req typedHello) (res typedBuy, err error) {
+		return
+	})
+	server.SetHandler("ping", namedHandler("pong"))
+
+	startTestServer(t, server)
+
+	client, err := NewClient(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	info, err := client.Reflect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(info.Topics) != 4 {
+		t.Fatalf("unexpected topics %v", info.Topics)
+	}
+
+	for _, ti := range info.Topics {
+		switch ti.Topic {
+		case "shop.buy":
+			if ti.Schema == nil || ti.Schema.Request.Name != "p2p.typedHello" ||
+				len(ti.Schema.Response.Fields) != 1 || ti.Schema.Response.Fields[0].Name != "Text" {
+				t.Fatalf("unexpected schema %v", ti.Schema)
+			}
+		case "ping", HealthTopic, ReflectionTopic:
+			if ti.Schema != nil {
+				t.Fatalf("unexpected schema %v", ti.Schema)
+			}
+		default:
+			t.Fatalf("unexpected topic %s", ti.Topic)
+		}
+	}
+
+	if len(info.Codecs) < 2 || len(info.Compressors) < 1 {
+		t.Fatalf("unexpected info %v", info)
+	}
+}
@@ -0,0 +1,278 @@
+package p2p
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	TopicSeparator = "."
+	TopicWildcard  = "*"
+	TopicTail      = ">"
+)
+
+type segmentKind uint8
+
+const (
+	literalSegment segmentKind = iota
+	paramSegment
+	wildcardSegment
+	tailSegment
+)
+
+type segment struct {
+	kind  segmentKind
+	value string
+}
+
+type route struct {
+	pattern  string
+	segments []segment
+	handler  Handler
+}
+
+type Router struct {
+	mx       sync.RWMutex
+	exact    map[string]Handler
+	routes   []route
+	fallback Handler
+	schemas  map[string]Schema
+}
+
+func NewRouter() (r *Router) {
+	return &Router{
+		mx:      sync.RWMutex{},
+		exact:   map[string]Handler{},
+		schemas: map[string]Schema{},
+	}
+}
+
+func (r *Router) SetHandler(pattern string, handler Handler) {
+	segments, literal := parsePattern(pattern)
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	delete(r.schemas, pattern)
+
+	if literal {
+		r.exact[pattern] = handler
+
+		return
+	}
+
+	for i := range r.routes {
+		if r.routes[i].pattern == pattern {
+			r.routes[i].handler = handler
+
+			return
+		}
+	}
+
+	r.routes = append(r.routes, route{
+		pattern:  pattern,
+		segments: segments,
+		handler:  handler,
+	})
+
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		return r.routes[i].before(r.routes[j])
+	})
+}
+
+func (r *Router) RemoveHandler(pattern string) (ok bool) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	delete(r.schemas, pattern)
+
+	_, ok = r.exact[pattern]
+	if ok {
+		delete(r.exact, pattern)
+
+		return
+	}
+
+	for i := range r.routes {
+		if r.routes[i].pattern == pattern {
+			r.routes = append(r.routes[:i:i], r.routes[i+1:]...)
+
+			return true
+		}
+	}
+
+	return
+}
+
+func (r *Router) Handlers() (patterns []string) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+
+	patterns = make([]string, 0, len(r.exact)+len(r.routes))
+	for pattern := range r.exact {
+		patterns = append(patterns, pattern)
+	}
+
+	for _, rt := range r.routes {
+		patterns = append(patterns, rt.pattern)
+	}
+
+	sort.Strings(patterns)
+
+	return
+}
+
+func (r *Router) Schema(pattern string) (schema Schema, ok bool) {
+	r.mx.RLock()
+	schema, ok = r.schemas[pattern]
+	r.mx.RUnlock()
+
+	return
+}
+
+func (r *Router) setSchema(pattern string, schema Schema) {
+	r.mx.Lock()
+	r.schemas[pattern] = schema
+	r.mx.Unlock()
+}
+
+func (r *Router) Group(prefix string) (g *Group) {
+	return newGroup(r, nil, prefix)
+}
+
+func (r *Router) SetDefaultHandler(handler Handler) {
+	r.mx.Lock()
+	r.fallback = handler
+	r.mx.Unlock()
+}
+
+func (r *Router) Lookup(topic string) (handler Handler, params Params, ok bool) {
+	_, handler, params, ok = r.lookup(topic)
+
+	return
+}
+
+// lookup also returns the pattern of the matched handler,
+// which is empty for the default handler.
+func (r *Router) lookup(topic string) (pattern string, handler Handler, params Params, ok bool) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+
+	handler, ok = r.exact[topic]
+	if ok {
+		return topic, handler, nil, true
+	}
+
+	parts := strings.Split(topic, TopicSeparator)
+	for _, rt := range r.routes {
+		params, ok = rt.match(parts)
+		if ok {
+			return rt.pattern, rt.handler, params, true
+		}
+	}
+
+	if r.fallback != nil {
+		return "", r.fallback, nil, true
+	}
+
+	return
+}
+
+func parsePattern(pattern string) (segments []segment, literal bool) {
+	literal = true
+
+	parts := strings.Split(pattern, TopicSeparator)
+	for i, part := range parts {
+		seg := segment{
+			kind:  literalSegment,
+			value: part,
+		}
+
+		switch {
+		case part == TopicTail && i == len(parts)-1:
+			seg.kind = tailSegment
+		case part == TopicWildcard:
+			seg.kind = wildcardSegment
+		case len(part) > 2 && strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}"):
+			seg.kind = paramSegment
+			seg.value = part[1 : len(part)-1]
+		}
+
+		if seg.kind != literalSegment {
+			literal = false
+		}
+
+		segments = append(segments, seg)
+	}
+
+	return
+}
+
+func (rt route) before(other route) (ok bool) {
+	for i := 0; i < len(rt.segments) && i < len(other.segments); i++ {
+		if rt.segments[i].kind != other.segments[i].kind {
+			return rt.segments[i].kind < other.segments[i].kind
+		}
+	}
+
+	if len(rt.segments) != len(other.segments) {
+		return len(rt.segments) > len(other.segments)
+	}
+
+	return rt.pattern < other.pattern
+}
+
+func (rt route) match(parts []string) (params Params, ok bool) {
+	for i, seg := range rt.segments {
+		if seg.kind == tailSegment {
+			return params, len(parts) > i
+		}
+
+		if i >= len(parts) {
+			return nil, false
+		}
+
+		switch seg.kind {
+		case literalSegment:
+			if parts[i] != seg.value {
+				return nil, false
+			}
+		case paramSegment:
+			if params == nil {
+				params = Params{}
+			}
+
+			params[seg.value] = parts[i]
+		}
+	}
+
+	if len(parts) != len(rt.segments) {
+		return nil, false
+	}
+
+	return params, true
+}
+
+type Params map[string]string
+
+type paramsKey struct{}
+
+func withParams(ctx context.Context, params Params) (c context.Context) {
+	if len(params) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, paramsKey{}, params)
+}
+
+func TopicParams(ctx context.Context) (params Params) {
+	params, _ = ctx.Value(paramsKey{}).(Params)
+
+	return
+}
+
+func TopicParam(ctx context.Context, name string) (value string) {
+	return TopicParams(ctx)[name]
+}
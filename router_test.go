@@ -0,0 +1,73 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+)
+
+func namedHandler(name string) (handler Handler) {
+	return func(ctx context.Context, req Data) (res Data, err error) {
+		res.SetBytes([]byte(name))
+
+		return
+	}
+}
+
+func TestRouter(t *testing.T) {
+	r := NewRouter()
+	r.SetHandler("orders.eu.created", namedHandler("exact"))
+	r.SetHandler("orders.{region}.created", namedHandler("param"))
+	r.SetHandler("orders.*.created", namedHandler("wildcard"))
+	r.SetHandler("orders.*.*", namedHandler("wildcards"))
+	r.SetHandler("orders.>", namedHandler("tail"))
+
+	for topic, want := range map[string]string{
+		"orders.eu.created":    "exact",
+		"orders.us.created":    "param",
+		"orders.us.deleted":    "wildcards",
+		"orders.us.created.v2": "tail",
+		"orders.us":            "tail",
+	} {
+		handler, _, ok := r.Lookup(topic)
+		if !ok {
+			t.Fatalf("%s: handler is not found", topic)
+		}
+
+		res, _ := handler(context.Background(), Data{})
+		if res.String() != want {
+			t.Fatalf("%s: unexpected handler %s", topic, res.String())
+		}
+	}
+
+	_, _, ok := r.Lookup("orders")
+	if ok {
+		t.Fatal("orders: unexpected handler")
+	}
+
+	r.SetDefaultHandler(namedHandler("default"))
+
+	handler, _, ok := r.Lookup("orders")
+	if !ok {
+		t.Fatal("orders: default handler is not found")
+	}
+
+	res, _ := handler(context.Background(), Data{})
+	if res.String() != "default" {
+		t.Fatalf("orders: unexpected handler %s", res.String())
+	}
+}
+
+func TestRouterParams(t *testing.T) {
+	r := NewRouter()
+	r.SetHandler("orders.{region}.{id}", namedHandler("param"))
+
+	_, params, ok := r.Lookup("orders.eu.42")
+	if !ok {
+		t.Fatal("handler is not found")
+	}
+
+	ctx := withParams(context.Background(), params)
+	if TopicParam(ctx, "region") != "eu" || TopicParam(ctx, "id") != "42" {
+		t.Fatalf("unexpected params %v", TopicParams(ctx))
+	}
+}
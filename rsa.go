@@ -3,7 +3,10 @@ package p2p
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
 )
 
 type RSA struct {
@@ -44,6 +47,13 @@ func (pk PublicKey) Encode(ck CipherKey) (cck CryptCipherKey, err error) {
 	return
 }
 
+// Fingerprint is a hex SHA-256 of the PKCS #1 encoded key.
+func (pk PublicKey) Fingerprint() (fp string) {
+	sum := sha256.Sum256(x509.MarshalPKCS1PublicKey(&pk.Key))
+
+	return hex.EncodeToString(sum[:])
+}
+
 type PrivateKey struct {
 	key rsa.PrivateKey
 }
@@ -0,0 +1,64 @@
+package p2p
+
+import "reflect"
+
+type Schema struct {
+	Request  TypeSchema `json:"request"`
+	Response TypeSchema `json:"response"`
+}
+
+type TypeSchema struct {
+	Name   string        `json:"name"`
+	Kind   string        `json:"kind"`
+	Fields []FieldSchema `json:"fields,omitempty"`
+}
+
+type FieldSchema struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type schemaSetter interface {
+	setSchema(topic string, schema Schema)
+}
+
+func setSchema(setter HandlerSetter, topic string, req, res reflect.Type) {
+	ss, ok := setter.(schemaSetter)
+	if !ok {
+		return
+	}
+
+	ss.setSchema(topic, Schema{
+		Request:  newTypeSchema(req),
+		Response: newTypeSchema(res),
+	})
+}
+
+func newTypeSchema(typ reflect.Type) (ts TypeSchema) {
+	ts = TypeSchema{
+		Name: typ.String(),
+		Kind: typ.Kind().String(),
+	}
+
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		ts.Fields = append(ts.Fields, FieldSchema{
+			Name: field.Name,
+			Type: field.Type.String(),
+		})
+	}
+
+	return
+}
@@ -4,19 +4,40 @@ import (
 	"context"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Server struct {
 	tcp *TCP
 	rsa *RSA
 
-	settings *ServerSettings
-	logger   Logger
+	// settings holds *ServerSettings that aren't changed after they're stored
+	settings        atomic.Value
+	settingsMx      sync.Mutex
+	settingsHandler SettingsHandler
+	level           *levelVar
+	logger          fieldLogger
 
 	ctx context.Context
 
-	mx       sync.RWMutex
-	handlers map[string]Handler
+	mx           sync.RWMutex
+	router       *Router
+	linkHandler  LinkHandler
+	errorHandler ErrorHandler
+	health       *health
+	gate         *gate
+	rates        *rateLimiter
+	instruments  *instruments
+	observer     Observer
+	tracer       Tracer
+	hooks        Hooks
+
+	listener net.Listener
+	closed   bool
+	conns    sync.WaitGroup
+	connSeq  uint64
+	peers    map[*Peer]struct{}
 }
 
 func NewServer(tcp *TCP) (s *Server, err error) {
@@ -26,19 +47,35 @@ func NewServer(tcp *TCP) (s *Server, err error) {
 		return
 	}
 
-	tcp.cipherKey = &ck
+	tcp.cipherKey, err = newSessionKey(ck)
+	if err != nil {
+		return
+	}
+
+	level := newLevelVar(DefaultLogLevel)
 
 	s = &Server{
 		tcp:    tcp,
-		logger: NewStdLogger(),
+		level:  level,
+		logger: newFieldLogger(NewStdLogger()).withLevel(level),
 
 		ctx: context.Background(),
 
-		mx:       sync.RWMutex{},
-		handlers: map[string]Handler{},
+		mx:     sync.RWMutex{},
+		router: NewRouter(),
+		health: newHealth(),
+		gate:   newGate(),
+		rates:  newRateLimiter(),
+
+		instruments: newInstruments(ServerRole),
+		tracer:      nopTracer{},
+
+		peers: map[*Peer]struct{}{},
 	}
 
-	s.settings = NewServerSettings()
+	s.SetHandler(HealthTopic, s.health.handle)
+
+	s.settings.Store(NewServerSettings())
 
 	s.rsa, err = NewRSA()
 
@@ -46,8 +83,113 @@ func NewServer(tcp *TCP) (s *Server, err error) {
 }
 
 func (s *Server) SetHandler(topic string, handler Handler) {
+	s.router.SetHandler(topic, handler)
+}
+
+func (s *Server) setSchema(topic string, schema Schema) {
+	s.router.setSchema(topic, schema)
+}
+
+func (s *Server) RemoveHandler(topic string) (ok bool) {
+	return s.router.RemoveHandler(topic)
+}
+
+func (s *Server) Handlers() (topics []string) {
+	return s.router.Handlers()
+}
+
+func (s *Server) Group(prefix string) (g *Group) {
+	return s.router.Group(prefix)
+}
+
+func (s *Server) SetDefaultHandler(handler Handler) {
+	s.router.SetDefaultHandler(handler)
+}
+
+func (s *Server) Collector() (collector Collector) {
+	return s.instruments
+}
+
+func (s *Server) SetObserver(observer Observer) {
 	s.mx.Lock()
-	s.handlers[topic] = handler
+	s.observer = observer
+	s.mx.Unlock()
+}
+
+func (s *Server) SetTracer(tracer Tracer) {
+	if tracer == nil {
+		tracer = nopTracer{}
+	}
+
+	s.mx.Lock()
+	s.tracer = tracer
+	s.mx.Unlock()
+}
+
+func (s *Server) getTracer() (tracer Tracer) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	return s.tracer
+}
+
+func (s *Server) SetHooks(hooks Hooks) {
+	s.mx.Lock()
+	s.hooks = hooks
+	s.mx.Unlock()
+}
+
+func (s *Server) getHooks() (hooks Hooks) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	return s.hooks
+}
+
+func (s *Server) observe(metrics *Metrics, conn *Conn, err error) {
+	metrics.setBytes(conn)
+
+	s.instruments.end(metrics, err)
+
+	s.mx.RLock()
+	observer := s.observer
+	hooks := s.hooks
+	s.mx.RUnlock()
+
+	if observer == nil && hooks.Exchange == nil {
+		return
+	}
+
+	stats := metrics.stats(ServerRole, err)
+
+	if observer != nil {
+		observer(stats)
+	}
+
+	hooks.exchange(conn.info, stats)
+}
+
+// SetErrorHandler sets a handler of errors that happen while connections are accepted or settings are watched.
+// Serve continues after temporary errors and returns after others.
+func (s *Server) SetErrorHandler(handler ErrorHandler) {
+	s.mx.Lock()
+	s.errorHandler = handler
+	s.mx.Unlock()
+}
+
+func (s *Server) reportError(err error) {
+	s.mx.RLock()
+	handler := s.errorHandler
+	s.mx.RUnlock()
+
+	if handler != nil {
+		handler(err)
+	}
+}
+
+func (s *Server) SetLinkHandler(handler LinkHandler) {
+	s.mx.Lock()
+	s.linkHandler = handler
 	s.mx.Unlock()
 }
 
@@ -63,12 +205,59 @@ func (s *Server) GetContext() (ctx context.Context) {
 	return s.ctx
 }
 
-func (s *Server) SetSettings(settings *ServerSettings) {
-	s.settings = settings
+// SetSettings validates and applies a copy of the settings at once, so they can be changed while serving.
+// New connections and requests use new settings, max conns changes the worker pool of Serve,
+// but the accept queue size is changed on the next Serve.
+func (s *Server) SetSettings(settings *ServerSettings) (err error) {
+	err = settings.Validate()
+	if err != nil {
+		return
+	}
+
+	next := *settings
+
+	// handlers get changes in order
+	s.settingsMx.Lock()
+	defer s.settingsMx.Unlock()
+
+	prev := s.getSettings()
+	s.settings.Store(&next)
+	s.level.set(next.level)
+
+	s.mx.RLock()
+	handler := s.settingsHandler
+	s.mx.RUnlock()
+
+	if handler != nil {
+		// the stored settings aren't changed by the handler
+		cp := next
+		handler(&prev, &cp)
+	}
+
+	return
+}
+
+// Settings returns a copy of current settings.
+func (s *Server) Settings() (settings *ServerSettings) {
+	stg := s.getSettings()
+
+	return &stg
+}
+
+func (s *Server) getSettings() (settings ServerSettings) {
+	return *s.settings.Load().(*ServerSettings)
+}
+
+// SetSettingsHandler sets a handler that is called with copies of previous and next settings after they're changed.
+// Handlers are called in order of changes, so a handler can't set settings itself.
+func (s *Server) SetSettingsHandler(handler SettingsHandler) {
+	s.mx.Lock()
+	s.settingsHandler = handler
+	s.mx.Unlock()
 }
 
 func (s *Server) SetLogger(logger Logger) {
-	s.logger = logger
+	s.logger = newFieldLogger(logger).withLevel(s.level)
 }
 
 func (s *Server) Serve() (err error) {
@@ -78,61 +267,287 @@ func (s *Server) Serve() (err error) {
 		return
 	}
 
+	err = s.ServeListener(listener)
+
+	return
+}
+
+// ServeListener serves connections of the listener and closes it on return.
+// Temporary accept errors and failed connections don't stop serving.
+func (s *Server) ServeListener(listener net.Listener) (err error) {
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+
+		err = listener.Close()
+		if err != nil {
+			s.logger.Error(err.Error())
+		}
+
+		return ServerClosedError
+	}
+	s.listener = listener
+	s.mx.Unlock()
+
 	defer func() {
+		if s.isClosed() {
+			return
+		}
+
 		err := listener.Close()
 		if err != nil {
 			s.logger.Error(err.Error())
 		}
 	}()
 
+	pool := s.newPool(s.getSettings().queue)
+	defer pool.close()
+
+	shedding := newSemaphore(maxShedding)
+
 	var (
 		conn    net.Conn
-		wrapped Conn
+		wrapped *Conn
+		delay   time.Duration
 	)
 	for {
 		conn, err = listener.Accept()
 		if err != nil {
-			s.logger.Error(err.Error())
+			if s.isClosed() {
+				return ServerClosedError
+			}
 
-			return
+			if !isTemporary(err) {
+				s.logger.Error("listener is failed", Field{Key: "error", Value: err})
+				s.reportError(err)
+
+				return
+			}
+
+			delay = backoff(delay)
+
+			s.logger.Warn("connection is not accepted", Field{Key: "error", Value: err}, Field{Key: "retry", Value: delay})
+			s.reportError(err)
+
+			time.Sleep(delay)
+
+			continue
 		}
 
-		wrapped, err = NewConn(conn, s.settings.Limiter)
+		delay = 0
+
+		settings := s.getSettings()
+
+		wrapped, err = NewConn(conn, settings.Limiter)
 		if err != nil {
-			s.logger.Error(err.Error())
+			s.logger.Error("connection is dropped", Field{Key: "addr", Value: conn.RemoteAddr().String()}, Field{Key: "error", Value: err})
+			s.reportError(err)
+
+			err = conn.Close()
+			if err != nil {
+				s.logger.Error(err.Error())
+			}
+
+			continue
+		}
 
+		wrapped.info = newConnInfo(&s.connSeq, ServerRole, wrapped)
+
+		s.mx.Lock()
+		if s.closed {
+			s.mx.Unlock()
+
+			err = conn.Close()
+			if err != nil {
+				s.logger.Error(err.Error())
+			}
+
+			return ServerClosedError
+		}
+		s.conns.Add(1)
+		s.mx.Unlock()
+
+		pool.resize(settings.conns)
+
+		if settings.conns == 0 {
+			go s.processConn(wrapped, settings)
+
+			continue
+		}
+
+		s.enqueue(pool, wrapped, settings.acceptTimeout, shedding)
+	}
+}
+
+// enqueue waits in background until the connection is taken by a worker or the timeout passes, then sheds it.
+// The accept loop isn't blocked by waiting.
+func (s *Server) enqueue(pool *pool, conn *Conn, timeout time.Duration, shedding semaphore) {
+	select {
+	case pool.queue <- conn:
+		return
+	default:
+	}
+
+	if timeout > 0 && pool.waiting.acquire() {
+		pool.waiters.Add(1)
+
+		go func() {
+			defer pool.waiters.Done()
+			defer pool.waiting.release()
+
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+
+			select {
+			case pool.queue <- conn:
+				return
+			case <-timer.C:
+			case <-pool.stop:
+			}
+
+			s.reject(conn, shedding)
+		}()
+
+		return
+	}
+
+	s.reject(conn, shedding)
+}
+
+// reject sheds the connection or closes it without an answer when too many connections are shed.
+func (s *Server) reject(conn *Conn, shedding semaphore) {
+	if shedding.acquire() {
+		go s.shed(conn, shedding)
+
+		return
+	}
+
+	hooks := s.getHooks()
+	hooks.connOpened(conn.info)
+
+	err := conn.Close()
+	if err != nil {
+		s.logger.Error(err.Error())
+	}
+
+	hooks.connClosed(conn.info, Overloaded)
+
+	s.conns.Done()
+}
+
+func (s *Server) Shutdown(ctx context.Context) (err error) {
+	s.health.stop()
+
+	select {
+	case <-time.After(s.getSettings().shutdown):
+	case <-ctx.Done():
+	}
+
+	s.mx.Lock()
+	s.closed = true
+	listener := s.listener
+	peers := make([]*Peer, 0, len(s.peers))
+	for peer := range s.peers {
+		peers = append(peers, peer)
+	}
+	s.mx.Unlock()
+
+	if listener != nil {
+		err = listener.Close()
+		if err != nil {
 			return
 		}
+	}
+
+	// linked peers don't finish by themselves
+	for _, peer := range peers {
+		cerr := peer.Close()
+		if cerr != nil {
+			s.logger.Error(cerr.Error())
+		}
+	}
 
-		go s.processConn(wrapped, *s.settings)
+	done := make(chan struct{})
+	go func() {
+		s.conns.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	return
+}
+
+// addPeer tracks a linked peer, so Shutdown closes it.
+func (s *Server) addPeer(peer *Peer) (err error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.closed {
+		return ServerClosedError
 	}
+
+	s.peers[peer] = struct{}{}
+
+	return
+}
+
+func (s *Server) removePeer(peer *Peer) {
+	s.mx.Lock()
+	delete(s.peers, peer)
+	s.mx.Unlock()
+}
+
+func (s *Server) isClosed() (ok bool) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	return s.closed
 }
 
-func (s *Server) processConn(conn Conn, settings ServerSettings) {
+func (s *Server) processConn(conn *Conn, settings ServerSettings) {
+	defer s.conns.Done()
+
+	hooks := s.getHooks()
+	hooks.connOpened(conn.info)
+
+	var err error
 	defer func() {
-		err := conn.Close()
-		if err != nil {
-			s.logger.Error(err.Error())
+		cerr := conn.Close()
+		if cerr != nil {
+			s.logger.Error(cerr.Error())
 		}
+
+		hooks.connClosed(conn.info, err)
 	}()
 
 	var (
 		p Package
 
 		metrics = newMetrics(conn.RemoteAddr().String())
-
-		err error
 	)
+
+	// the handshake timeout limits reading of Hello too, a client with a cipher key starts without a handshake
+	conn.beginHandshake()
+
 	for {
 		err = conn.ReadPackage(&p)
 		if err != nil {
-			s.logger.Error(err.Error())
+			s.logger.Error("package is not read", Field{Key: "addr", Value: metrics.addr}, Field{Key: "error", Value: err})
 
 			return
 		}
 
+		if p.Type != Handshake {
+			conn.endHandshake()
+		}
+
 		err = s.processPackage(conn, settings, p, metrics)
-		if p.Type == Exchange {
+		if p.Type == Exchange || p.Type == Link {
 			break
 		}
 
@@ -142,20 +557,22 @@ func (s *Server) processConn(conn Conn, settings ServerSettings) {
 	}
 
 	if err != nil {
-		s.logger.Error(err.Error())
+		s.logger.Error("package is not processed", append(metrics.fields(), Field{Key: "error", Value: err})...)
 
 		return
 	}
 
-	s.logger.Info(metrics.string())
+	s.logger.access(metrics, settings.sampling)
 }
 
-func (s *Server) processPackage(conn Conn, settings ServerSettings, p Package, metrics *Metrics) (err error) {
+func (s *Server) processPackage(conn *Conn, settings ServerSettings, p Package, metrics *Metrics) (err error) {
 	switch p.Type {
 	case Handshake:
 		err = s.doHandshake(conn, p, metrics)
 	case Exchange:
 		err = s.doExchange(conn, p, settings, metrics)
+	case Link:
+		err = s.doLink(conn, p, settings, metrics)
 	default:
 		err = UnsupportedPackage
 	}
@@ -163,24 +580,63 @@ func (s *Server) processPackage(conn Conn, settings ServerSettings, p Package, m
 	return
 }
 
-func (s *Server) doHandshake(conn Conn, p Package, metrics *Metrics) (err error) {
-	var pk PublicKey
-	err = p.GetGob(&pk)
+func (s *Server) doHandshake(conn *Conn, p Package, metrics *Metrics) (err error) {
+	defer conn.endHandshake()
+
+	var hello Hello
+	err = conn.getPayload(p, &hello)
 	if err != nil {
 		s.logger.Error(err.Error())
 
 		return
 	}
 
+	var session Session
+	session, err = negotiate(hello)
+	if err != nil {
+		s.logger.Warn(err.Error())
+
+		p = Package{
+			Type: Error,
+		}
+
+		werr := conn.setPayload(&p, err)
+		if werr == nil {
+			werr = conn.WritePackage(p)
+		}
+
+		if werr != nil {
+			s.logger.Error(werr.Error())
+		}
+
+		return
+	}
+
+	conn.setLegacy(session.Capabilities)
+
+	pk := PublicKey{
+		Key: hello.Key,
+	}
+
 	var cck CryptCipherKey
-	cck, err = pk.Encode(*s.tcp.cipherKey)
+	cck, err = pk.Encode(s.tcp.cipherKey.key)
 	if err != nil {
 		s.logger.Error(err.Error())
 
 		return
 	}
 
-	err = p.SetGob(cck)
+	if session.Version == LegacyProtocolVersion {
+		err = conn.setPayload(&p, cck)
+	} else {
+		err = conn.setPayload(&p, Welcome{
+			Key:          cck,
+			Version:      session.Version,
+			CipherSuite:  session.CipherSuite,
+			Capabilities: session.Capabilities,
+		})
+	}
+
 	if err != nil {
 		s.logger.Error(err.Error())
 
@@ -196,12 +652,23 @@ func (s *Server) doHandshake(conn Conn, p Package, metrics *Metrics) (err error)
 
 	metrics.fixHandshake()
 
+	s.getHooks().handshake(HandshakeInfo{
+		ConnInfo:    conn.info,
+		Session:     session,
+		Fingerprint: pk.Fingerprint(),
+	})
+
 	return
 }
 
-func (s *Server) doExchange(conn Conn, p Package, settings ServerSettings, metrics *Metrics) (err error) {
+func (s *Server) doExchange(conn *Conn, p Package, settings ServerSettings, metrics *Metrics) (err error) {
+	s.instruments.begin()
+	defer func() {
+		s.observe(metrics, conn, err)
+	}()
+
 	var cm CryptMessage
-	err = p.GetGob(&cm)
+	err = conn.getPayload(p, &cm)
 	if err != nil {
 		s.logger.Error(err.Error())
 
@@ -209,13 +676,37 @@ func (s *Server) doExchange(conn Conn, p Package, settings ServerSettings, metri
 	}
 
 	var msg Message
-	msg, err = cm.Decode(*s.tcp.cipherKey)
+	msg, err = conn.decodeMessage(cm, s.tcp.cipherKey)
 	if err != nil {
 		s.logger.Warn(err.Error())
 
-		err = s.sendError(conn, metrics)
-		if err != nil {
-			s.logger.Error(err.Error())
+		metrics.setError(err)
+
+		werr := s.sendError(conn, metrics)
+		if werr != nil {
+			s.logger.Error(werr.Error())
+		}
+
+		return
+	}
+
+	compression := settings.Compression.reply(msg.Compression)
+
+	err = msg.decompress(settings.decompression)
+	if err != nil {
+		s.logger.Warn(err.Error(), Field{Key: "topic", Value: msg.Topic})
+
+		metrics.setTopic(msg.Topic)
+		metrics.setError(err)
+
+		msg.Compression = ""
+		msg.setData(Data{})
+		msg.Metadata = nil
+		msg.setError(err)
+
+		werr := s.reply(conn, p, msg, Compression{}, metrics)
+		if werr != nil {
+			s.logger.Error(werr.Error())
 		}
 
 		return
@@ -227,44 +718,89 @@ func (s *Server) doExchange(conn Conn, p Package, settings ServerSettings, metri
 	var (
 		ctx    context.Context
 		cancel context.CancelFunc
-
-		handler Handler
-		ok      bool
 	)
 
-	s.mx.RLock()
-	ctx, cancel = context.WithTimeout(s.ctx, settings.Timeout.handle)
+	ctx, cancel = settings.Timeout.handleContext(s.GetContext())
 	defer cancel()
 
-	handler, ok = s.handlers[msg.Topic]
-	s.mx.RUnlock()
+	var span Span
+	ctx, span = s.getTracer().Start(ctx, ServerSpan, msg.Topic, msg.Metadata)
+	defer func() {
+		spanErr := err
+		if spanErr == nil {
+			spanErr = metrics.err
+		}
 
-	if !ok {
-		s.logger.Warn(UnsupportedTopic.Error())
+		span.End(spanErr)
+	}()
 
-		return
+	metrics.setSpan(span)
+
+	var holder *metadataHolder
+	ctx, holder = withMetadata(ctx, msg.Metadata)
+
+	var res Data
+
+	pattern, handler, params, ok := s.router.lookup(msg.Topic)
+
+	route := pattern
+	if route == "" {
+		route = defaultRoute
 	}
 
-	var req, res Data
-	req.SetBytes(msg.Content)
-	res, err = handler(ctx, req)
-	if err != nil {
-		s.logger.Error(err.Error())
+	var release func()
+	if ok {
+		release, err = s.admit(remoteHost(conn), pattern, settings)
+	}
+
+	switch {
+	case ok && err == nil:
+		res, err = handler(withParams(ctx, params), msg.data())
+		release()
+
+		if err != nil {
+			s.logger.Error("handler failed", Field{Key: "topic", Value: msg.Topic}, Field{Key: "error", Value: err})
+		}
+	case ok:
+		s.logger.Warn(err.Error(), Field{Key: "topic", Value: msg.Topic})
+	default:
+		route = unsupportedRoute
+		err = UnsupportedTopic
+
+		s.logger.Warn(err.Error(), Field{Key: "topic", Value: msg.Topic})
 	}
 
-	msg.Content = res.GetBytes()
-	msg.Error = err
+	metrics.setRoute(route)
+	metrics.setError(err)
+
+	msg.setData(res)
+	msg.Metadata = holder.outgoing()
+	msg.setError(err)
 
 	metrics.fixHandleDuration()
 
-	cm, err = msg.Encode(*s.tcp.cipherKey)
+	err = s.reply(conn, p, msg, compression, metrics)
+
+	return
+}
+
+func (s *Server) reply(conn *Conn, p Package, msg Message, compression Compression, metrics *Metrics) (err error) {
+	err = msg.compress(compression)
+	if err != nil {
+		s.logger.Error(err.Error())
+
+		return
+	}
+
+	var cm CryptMessage
+	cm, err = conn.encodeMessage(msg, s.tcp.cipherKey)
 	if err != nil {
 		s.logger.Error(err.Error())
 
 		return
 	}
 
-	err = p.SetGob(cm)
+	err = conn.setPayload(&p, cm)
 	if err != nil {
 		s.logger.Error(err.Error())
 
@@ -283,7 +819,84 @@ func (s *Server) doExchange(conn Conn, p Package, settings ServerSettings, metri
 	return
 }
 
-func (s *Server) sendError(conn Conn, metrics *Metrics) (err error) {
+func (s *Server) doLink(conn *Conn, p Package, settings ServerSettings, metrics *Metrics) (err error) {
+	var cm CryptMessage
+	err = conn.getPayload(p, &cm)
+	if err != nil {
+		s.logger.Error(err.Error())
+
+		return
+	}
+
+	var msg Message
+	msg, err = conn.decodeMessage(cm, s.tcp.cipherKey)
+	if err != nil {
+		s.logger.Warn(err.Error())
+
+		metrics.setError(err)
+
+		werr := s.sendError(conn, metrics)
+		if werr != nil {
+			s.logger.Error(werr.Error())
+		}
+
+		return
+	}
+
+	var caps Capabilities
+	if len(msg.Content) > 0 {
+		data := msg.data()
+		err = data.Decode(&caps)
+		if err != nil {
+			s.logger.Error(err.Error())
+
+			return
+		}
+	}
+
+	session := Session{
+		Capabilities: localCapabilities().intersect(caps),
+	}
+
+	conn.setLegacy(session.Capabilities)
+	conn.stream()
+	conn.link()
+
+	peer := newPeer(conn, s.tcp.cipherKey, s.logger, s.GetContext(), session.compression(settings.Compression), s.router)
+	host := remoteHost(conn)
+	peer.admit = func(pattern string) (release func(), err error) {
+		return s.admit(host, pattern, settings)
+	}
+
+	err = s.addPeer(peer)
+	if err != nil {
+		s.logger.Warn(err.Error())
+
+		return
+	}
+	defer s.removePeer(peer)
+
+	err = peer.accept()
+	if err != nil {
+		s.logger.Error(err.Error())
+
+		return
+	}
+
+	s.mx.RLock()
+	linkHandler := s.linkHandler
+	s.mx.RUnlock()
+
+	if linkHandler != nil {
+		go linkHandler(peer)
+	}
+
+	err = peer.serve()
+
+	return
+}
+
+func (s *Server) sendError(conn *Conn, metrics *Metrics) (err error) {
 	p := Package{
 		Type: Error,
 	}
@@ -1,31 +1,220 @@
 package p2p
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type ServerSettings struct {
 	Limiter
+	Compression
+	Concurrency
+	RateLimits
+	Logging
 }
 
 func NewServerSettings() (stg *ServerSettings) {
 	return &ServerSettings{
 		Limiter: Limiter{
-			Timeout: Timeout{
-				conn:   DefaultConnTimeout,
-				handle: DefaultHandleTimeout,
-			},
-			body: DefaultBodyLimit,
+			Timeout:       newTimeout(),
+			body:          DefaultBodyLimit,
+			decompression: DefaultDecompressionLimit,
+		},
+		Concurrency: Concurrency{
+			acceptTimeout: DefaultAcceptTimeout,
+		},
+		Logging: Logging{
+			sampling: DefaultAccessLogSampling,
+			level:    DefaultLogLevel,
 		},
 	}
 }
 
+// SetConnTimeout sets timeouts of handshake, read, write and idle.
 func (stg *ServerSettings) SetConnTimeout(dur time.Duration) {
-	stg.Limiter.conn = dur
+	stg.Limiter.setConn(dur)
+}
+
+func (stg *ServerSettings) SetHandshakeTimeout(dur time.Duration) {
+	stg.Limiter.handshake = dur
+}
+
+// SetReadTimeout limits reading of a package after its first byte.
+func (stg *ServerSettings) SetReadTimeout(dur time.Duration) {
+	stg.Limiter.read = dur
+}
+
+func (stg *ServerSettings) SetWriteTimeout(dur time.Duration) {
+	stg.Limiter.write = dur
+}
+
+// SetIdleTimeout limits waiting for the next package, links wait for packages without it.
+func (stg *ServerSettings) SetIdleTimeout(dur time.Duration) {
+	stg.Limiter.idle = dur
 }
 
 func (stg *ServerSettings) SetHandleTimeout(dur time.Duration) {
 	stg.Limiter.handle = dur
 }
 
+func (stg *ServerSettings) SetShutdownDelay(dur time.Duration) {
+	stg.Limiter.shutdown = dur
+}
+
 func (stg *ServerSettings) SetBodyLimit(limit uint) {
 	stg.Limiter.body = int(limit)
 }
+
+// SetDecompressionLimit limits a size of decompressed content, zero turns the limit off.
+func (stg *ServerSettings) SetDecompressionLimit(limit uint) {
+	stg.Limiter.decompression = int(limit)
+}
+
+func (stg *ServerSettings) SetCompression(name string, threshold uint) {
+	stg.Compression.name = name
+	stg.Compression.threshold = int(threshold)
+}
+
+// SetMaxConns limits connections that are processed at the same time.
+// Other accepted connections wait in the accept queue.
+func (stg *ServerSettings) SetMaxConns(limit uint) {
+	stg.Concurrency.conns = int(limit)
+}
+
+// SetAcceptQueue sets how many accepted connections can wait for processing and how long.
+// Connections that don't fit the queue or wait too long are rejected with Overloaded error.
+func (stg *ServerSettings) SetAcceptQueue(size uint, timeout time.Duration) {
+	stg.Concurrency.queue = int(size)
+	stg.Concurrency.acceptTimeout = timeout
+}
+
+// SetMaxHandlers limits handlers that run at the same time, excess requests get Overloaded error.
+func (stg *ServerSettings) SetMaxHandlers(limit uint) {
+	stg.Concurrency.handlers = int(limit)
+}
+
+// SetTopicMaxHandlers limits handlers of the topic or topic pattern that run at the same time.
+func (stg *ServerSettings) SetTopicMaxHandlers(topic string, limit uint) {
+	topics := make(map[string]int, len(stg.Concurrency.topics)+1)
+	for t, l := range stg.Concurrency.topics {
+		topics[t] = l
+	}
+
+	if limit == 0 {
+		delete(topics, topic)
+	} else {
+		topics[topic] = int(limit)
+	}
+
+	stg.Concurrency.topics = topics
+}
+
+// SetPeerRateLimit limits requests per second of every remote IP, bursts can take up to the burst requests.
+// Excess requests get RateLimitError, zero limit turns the limit off.
+func (stg *ServerSettings) SetPeerRateLimit(limit float64, burst uint) {
+	stg.RateLimits.requests = newRateLimit(limit, burst)
+}
+
+// SetTopicRateLimit limits requests per second of the topic or topic pattern from all peers.
+func (stg *ServerSettings) SetTopicRateLimit(topic string, limit float64, burst uint) {
+	stg.RateLimits.setTopic(topic, newRateLimit(limit, burst))
+}
+
+// SetAccessLogSampling sets a share of requests that are logged: 1 logs all requests, 0 turns the access log off.
+func (stg *ServerSettings) SetAccessLogSampling(rate float64) {
+	stg.Logging.sampling = rate
+}
+
+// SetLogLevel sets a minimum level of server records, the logger can filter them more.
+func (stg *ServerSettings) SetLogLevel(level Level) {
+	stg.Logging.level = level
+}
+
+type SettingsError struct {
+	Setting string
+	Reason  string
+}
+
+func (e *SettingsError) Error() (str string) {
+	return fmt.Sprintf("%s: %s %s", InvalidSettings, e.Setting, e.Reason)
+}
+
+func (e *SettingsError) Unwrap() (err error) {
+	return InvalidSettings
+}
+
+// Validate checks settings before they're applied by SetSettings.
+func (stg *ServerSettings) Validate() (err error) {
+	if stg == nil {
+		return InvalidSettings
+	}
+
+	for _, timeout := range []struct {
+		setting string
+		dur     time.Duration
+	}{
+		{"handshake timeout", stg.Limiter.handshake},
+		{"read timeout", stg.Limiter.read},
+		{"write timeout", stg.Limiter.write},
+		{"idle timeout", stg.Limiter.idle},
+		{"handle timeout", stg.Limiter.handle},
+		{"shutdown delay", stg.Limiter.shutdown},
+		{"accept timeout", stg.Concurrency.acceptTimeout},
+	} {
+		if timeout.dur < 0 {
+			return &SettingsError{
+				Setting: timeout.setting,
+				Reason:  "is negative",
+			}
+		}
+	}
+
+	if stg.Limiter.body < 0 {
+		return &SettingsError{
+			Setting: "body limit",
+			Reason:  "is too big",
+		}
+	}
+
+	if stg.Limiter.decompression < 0 {
+		return &SettingsError{
+			Setting: "decompression limit",
+			Reason:  "is too big",
+		}
+	}
+
+	if stg.Compression.name != "" {
+		_, ok := GetCompressor(stg.Compression.name)
+		if !ok {
+			return &SettingsError{
+				Setting: "compression",
+				Reason:  fmt.Sprintf("has unsupported compressor %q", stg.Compression.name),
+			}
+		}
+	}
+
+	if stg.RateLimits.requests.limit < 0 {
+		return &SettingsError{
+			Setting: "peer rate limit",
+			Reason:  "is negative",
+		}
+	}
+
+	for topic, limit := range stg.RateLimits.topics {
+		if limit.limit < 0 {
+			return &SettingsError{
+				Setting: fmt.Sprintf("rate limit of %q topic", topic),
+				Reason:  "is negative",
+			}
+		}
+	}
+
+	if stg.Logging.sampling < 0 || stg.Logging.sampling > 1 {
+		return &SettingsError{
+			Setting: "access log sampling",
+			Reason:  "is out of [0, 1]",
+		}
+	}
+
+	return
+}
@@ -0,0 +1,165 @@
+package p2p
+
+import (
+	"context"
+	"reflect"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+func (s *Server) Register(service any) (err error) {
+	err = s.RegisterName("", service)
+
+	return
+}
+
+func (s *Server) RegisterName(name string, service any) (err error) {
+	var (
+		handlers map[string]Handler
+		schemas  map[string]Schema
+	)
+	handlers, schemas, err = serviceHandlers(name, service)
+	if err != nil {
+		return
+	}
+
+	for topic, handler := range handlers {
+		s.SetHandler(topic, handler)
+		s.setSchema(topic, schemas[topic])
+	}
+
+	return
+}
+
+func serviceHandlers(name string, service any) (handlers map[string]Handler, schemas map[string]Schema, err error) {
+	rcvr := reflect.ValueOf(service)
+	if name == "" {
+		name = reflect.Indirect(rcvr).Type().Name()
+	}
+
+	if name == "" {
+		err = InvalidService
+
+		return
+	}
+
+	handlers = map[string]Handler{}
+	schemas = map[string]Schema{}
+
+	typ := rcvr.Type()
+	for i := 0; i < typ.NumMethod(); i++ {
+		method := typ.Method(i)
+		if !isServiceMethod(method.Type) {
+			continue
+		}
+
+		topic := name + "." + method.Name
+		handlers[topic] = serviceHandler(topic, rcvr, method)
+		schemas[topic] = Schema{
+			Request:  newTypeSchema(method.Type.In(2)),
+			Response: newTypeSchema(method.Type.Out(0)),
+		}
+	}
+
+	if len(handlers) == 0 {
+		err = InvalidService
+	}
+
+	return
+}
+
+func isServiceMethod(mtype reflect.Type) (ok bool) {
+	return mtype.NumIn() == 3 &&
+		mtype.In(1) == contextType &&
+		mtype.In(2).Kind() == reflect.Pointer &&
+		mtype.NumOut() == 2 &&
+		mtype.Out(0).Kind() == reflect.Pointer &&
+		mtype.Out(1) == errorType
+}
+
+func serviceHandler(topic string, rcvr reflect.Value, method reflect.Method) (handler Handler) {
+	reqType := method.Type.In(2).Elem()
+	resType := method.Type.Out(0).Elem()
+
+	return func(ctx context.Context, in Data) (out Data, err error) {
+		req := reflect.New(reqType)
+		err = in.Decode(req.Interface())
+		if err != nil {
+			err = &DecodeError{
+				Topic: topic,
+				Codec: in.ContentType,
+				Err:   err,
+			}
+
+			return
+		}
+
+		rs := method.Func.Call([]reflect.Value{rcvr, reflect.ValueOf(ctx), req})
+		if !rs[1].IsNil() {
+			err = rs[1].Interface().(error)
+
+			return
+		}
+
+		res := rs[0]
+		if res.IsNil() {
+			res = reflect.New(resType)
+		}
+
+		err = out.Encode(in.ContentType, res.Interface())
+
+		return
+	}
+}
+
+type Stub struct {
+	sender  Sender
+	service string
+	codec   string
+}
+
+func NewStub(sender Sender, service string) (stub *Stub) {
+	return &Stub{
+		sender:  sender,
+		service: service,
+		codec:   GobCodecName,
+	}
+}
+
+func (stub *Stub) SetCodec(codec string) {
+	stub.codec = codec
+}
+
+func (stub *Stub) Call(method string, req any, res any) (err error) {
+	return stub.CallContext(context.Background(), method, req, res)
+}
+
+func (stub *Stub) CallContext(ctx context.Context, method string, req any, res any) (err error) {
+	topic := stub.service + "." + method
+
+	var in Data
+	err = in.Encode(stub.codec, req)
+	if err != nil {
+		return
+	}
+
+	var out Data
+	out, err = sendContext(ctx, stub.sender, topic, in)
+	if err != nil {
+		return
+	}
+
+	err = out.Decode(res)
+	if err != nil {
+		err = &DecodeError{
+			Topic: topic,
+			Codec: out.ContentType,
+			Err:   err,
+		}
+	}
+
+	return
+}
@@ -0,0 +1,90 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type Arith struct{}
+
+type ArithArgs struct {
+	A, B int
+}
+
+type ArithReply struct {
+	C int
+}
+
+func (Arith) Add(ctx context.Context, args *ArithArgs) (reply *ArithReply, err error) {
+	return &ArithReply{C: args.A + args.B}, nil
+}
+
+func (Arith) Div(ctx context.Context, args *ArithArgs) (reply *ArithReply, err error) {
+	if args.B == 0 {
+		return nil, errors.New("divide by zero")
+	}
+
+	return &ArithReply{C: args.A / args.B}, nil
+}
+
+func (Arith) Ignored(args ArithArgs) {}
+
+func TestServiceHandlers(t *testing.T) {
+	handlers, schemas, err := serviceHandlers("", Arith{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(handlers) != 2 {
+		t.Fatalf("unexpected handlers count %d", len(handlers))
+	}
+
+	if schemas["Arith.Add"].Request.Name != "*p2p.ArithArgs" {
+		t.Fatalf("unexpected schema %v", schemas["Arith.Add"])
+	}
+
+	_, _, err = serviceHandlers("", struct{}{})
+	if !errors.Is(err, InvalidService) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestStub(t *testing.T) {
+	handlers, _, err := serviceHandlers("", Arith{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stub := NewStub(senderFunc(func(topic string, req Data) (res Data, err error) {
+		handler, ok := handlers[topic]
+		if !ok {
+			err = UnsupportedTopic
+
+			return
+		}
+
+		return handler(context.Background(), req)
+	}), "Arith")
+	stub.SetCodec(JsonCodecName)
+
+	var reply ArithReply
+	err = stub.Call("Add", &ArithArgs{A: 2, B: 3}, &reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reply.C != 5 {
+		t.Fatalf("unexpected reply %d", reply.C)
+	}
+
+	err = stub.Call("Div", &ArithArgs{A: 2}, &reply)
+	if err == nil || err.Error() != "divide by zero" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	err = stub.Call("Mul", &ArithArgs{}, &reply)
+	if !errors.Is(err, UnsupportedTopic) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -1,22 +1,64 @@
 package p2p
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
-const DefaultBodyLimit = 1024
+const (
+	DefaultBodyLimit          = 1024
+	DefaultDecompressionLimit = 16 << 20
+)
 
+// Limiter body is a size of a read buffer and decompression limits a size of decompressed content.
 type Limiter struct {
 	Timeout
-	body int
+	body          int
+	decompression int
 }
 
 const (
 	DefaultConnTimeout   = 250 * time.Millisecond
+	DefaultIdleTimeout   = 5 * time.Second
 	DefaultHandleTimeout = 250 * time.Millisecond
 )
 
+// Timeout of handshake limits a whole handshake, timeouts of read and write limit every package,
+// timeout of idle limits waiting for the next package and timeout of handle limits a handler.
+// Zero timeout turns it off.
 type Timeout struct {
-	conn   time.Duration
-	handle time.Duration
+	handshake time.Duration
+	read      time.Duration
+	write     time.Duration
+	idle      time.Duration
+	handle    time.Duration
+	shutdown  time.Duration
+}
+
+func newTimeout() (t Timeout) {
+	return Timeout{
+		handshake: DefaultConnTimeout,
+		read:      DefaultConnTimeout,
+		write:     DefaultConnTimeout,
+		idle:      DefaultIdleTimeout,
+		handle:    DefaultHandleTimeout,
+	}
+}
+
+// handleContext returns a context of a handler, which isn't limited when the handle timeout is zero.
+func (t Timeout) handleContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if t.handle <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, t.handle)
+}
+
+func (t *Timeout) setConn(dur time.Duration) {
+	t.handshake = dur
+	t.read = dur
+	t.write = dur
+	t.idle = dur
 }
 
 const (
@@ -28,3 +70,60 @@ type Retry struct {
 	retries uint
 	delay   time.Duration
 }
+
+type Compression struct {
+	name      string
+	threshold int
+}
+
+type Transport struct {
+	wire string
+}
+
+const DefaultAcceptTimeout = 50 * time.Millisecond
+
+// Concurrency limits are unlimited when they're zero.
+type Concurrency struct {
+	conns         int
+	handlers      int
+	topics        map[string]int
+	queue         int
+	acceptTimeout time.Duration
+}
+
+func (c Concurrency) topicHandlers(topic string) (limit int) {
+	return c.topics[topic]
+}
+
+// RateLimits are limits of requests per second: requests of a peer on a server
+// or all requests on a client, and requests of a topic.
+type RateLimits struct {
+	requests rateLimit
+	topics   map[string]rateLimit
+}
+
+func (rl *RateLimits) setTopic(topic string, limit rateLimit) {
+	topics := make(map[string]rateLimit, len(rl.topics)+1)
+	for t, l := range rl.topics {
+		topics[t] = l
+	}
+
+	if limit.limit == 0 {
+		delete(topics, topic)
+	} else {
+		topics[topic] = limit
+	}
+
+	rl.topics = topics
+}
+
+const (
+	DefaultAccessLogSampling = 1.0
+	// DefaultLogLevel passes all records to a logger, which filters them by itself.
+	DefaultLogLevel = DebugLevel
+)
+
+type Logging struct {
+	sampling float64
+	level    Level
+}
@@ -0,0 +1,240 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+const DefaultWatchInterval = time.Second
+
+type SettingsHandler func(prev, next *ServerSettings)
+
+// SettingsDecoder decodes a settings file, e.g. json.Unmarshal or yaml.Unmarshal.
+type SettingsDecoder func(data []byte, v interface{}) (err error)
+
+// Duration is written in files as a string like "1m30s".
+type Duration time.Duration
+
+func (d Duration) MarshalText() (text []byte, err error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) (err error) {
+	var dur time.Duration
+	dur, err = time.ParseDuration(string(text))
+	if err != nil {
+		return
+	}
+
+	*d = Duration(dur)
+
+	return
+}
+
+type RateLimitFile struct {
+	Limit float64 `json:"limit" yaml:"limit"`
+	Burst uint    `json:"burst" yaml:"burst"`
+}
+
+// SettingsFile is a form of ServerSettings in a file, settings that are missed in the file keep default values.
+type SettingsFile struct {
+	HandshakeTimeout Duration `json:"handshake_timeout" yaml:"handshake_timeout"`
+	ReadTimeout      Duration `json:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout     Duration `json:"write_timeout" yaml:"write_timeout"`
+	IdleTimeout      Duration `json:"idle_timeout" yaml:"idle_timeout"`
+	HandleTimeout    Duration `json:"handle_timeout" yaml:"handle_timeout"`
+	ShutdownDelay    Duration `json:"shutdown_delay" yaml:"shutdown_delay"`
+
+	BodyLimit            uint   `json:"body_limit" yaml:"body_limit"`
+	DecompressionLimit   uint   `json:"decompression_limit" yaml:"decompression_limit"`
+	Compression          string `json:"compression" yaml:"compression"`
+	CompressionThreshold uint   `json:"compression_threshold" yaml:"compression_threshold"`
+
+	MaxConns         uint            `json:"max_conns" yaml:"max_conns"`
+	AcceptQueue      uint            `json:"accept_queue" yaml:"accept_queue"`
+	AcceptTimeout    Duration        `json:"accept_timeout" yaml:"accept_timeout"`
+	MaxHandlers      uint            `json:"max_handlers" yaml:"max_handlers"`
+	TopicMaxHandlers map[string]uint `json:"topic_max_handlers" yaml:"topic_max_handlers"`
+
+	PeerRateLimit   RateLimitFile            `json:"peer_rate_limit" yaml:"peer_rate_limit"`
+	TopicRateLimits map[string]RateLimitFile `json:"topic_rate_limits" yaml:"topic_rate_limits"`
+
+	AccessLogSampling float64 `json:"access_log_sampling" yaml:"access_log_sampling"`
+	LogLevel          Level   `json:"log_level" yaml:"log_level"`
+}
+
+func newSettingsFile() (f SettingsFile) {
+	stg := NewServerSettings()
+
+	return SettingsFile{
+		HandshakeTimeout: Duration(stg.Limiter.handshake),
+		ReadTimeout:      Duration(stg.Limiter.read),
+		WriteTimeout:     Duration(stg.Limiter.write),
+		IdleTimeout:      Duration(stg.Limiter.idle),
+		HandleTimeout:    Duration(stg.Limiter.handle),
+		ShutdownDelay:    Duration(stg.Limiter.shutdown),
+
+		BodyLimit:          uint(stg.Limiter.body),
+		DecompressionLimit: uint(stg.Limiter.decompression),
+
+		AcceptTimeout: Duration(stg.Concurrency.acceptTimeout),
+
+		AccessLogSampling: stg.Logging.sampling,
+		LogLevel:          stg.Logging.level,
+	}
+}
+
+func (f SettingsFile) ServerSettings() (stg *ServerSettings) {
+	stg = NewServerSettings()
+
+	stg.SetHandshakeTimeout(time.Duration(f.HandshakeTimeout))
+	stg.SetReadTimeout(time.Duration(f.ReadTimeout))
+	stg.SetWriteTimeout(time.Duration(f.WriteTimeout))
+	stg.SetIdleTimeout(time.Duration(f.IdleTimeout))
+	stg.SetHandleTimeout(time.Duration(f.HandleTimeout))
+	stg.SetShutdownDelay(time.Duration(f.ShutdownDelay))
+
+	stg.SetBodyLimit(f.BodyLimit)
+	stg.SetDecompressionLimit(f.DecompressionLimit)
+	stg.SetCompression(f.Compression, f.CompressionThreshold)
+
+	stg.SetMaxConns(f.MaxConns)
+	stg.SetAcceptQueue(f.AcceptQueue, time.Duration(f.AcceptTimeout))
+	stg.SetMaxHandlers(f.MaxHandlers)
+
+	for topic, limit := range f.TopicMaxHandlers {
+		stg.SetTopicMaxHandlers(topic, limit)
+	}
+
+	stg.SetPeerRateLimit(f.PeerRateLimit.Limit, f.PeerRateLimit.Burst)
+
+	for topic, limit := range f.TopicRateLimits {
+		stg.SetTopicRateLimit(topic, limit.Limit, limit.Burst)
+	}
+
+	stg.SetAccessLogSampling(f.AccessLogSampling)
+	stg.SetLogLevel(f.LogLevel)
+
+	return
+}
+
+// ParseSettings decodes settings by the decoder, JSON is decoded when it's nil.
+func ParseSettings(data []byte, decode SettingsDecoder) (stg *ServerSettings, err error) {
+	if decode == nil {
+		decode = json.Unmarshal
+	}
+
+	f := newSettingsFile()
+
+	err = decode(data, &f)
+	if err != nil {
+		return
+	}
+
+	stg = f.ServerSettings()
+
+	err = stg.Validate()
+	if err != nil {
+		stg = nil
+	}
+
+	return
+}
+
+func LoadSettings(path string, decode SettingsDecoder) (stg *ServerSettings, err error) {
+	var data []byte
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	stg, err = ParseSettings(data, decode)
+
+	return
+}
+
+// WatchSettings applies settings of the file and reapplies them every time the file is changed until ctx is done.
+// Errors of reading and invalid changes are passed to the error handler and keep current settings.
+func (s *Server) WatchSettings(ctx context.Context, path string, decode SettingsDecoder, interval time.Duration) (err error) {
+	var data []byte
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	err = s.applySettings(data, decode)
+	if err != nil {
+		return
+	}
+
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	go s.watchSettings(ctx, path, decode, interval, data)
+
+	return
+}
+
+// watchSettings applies a changed file when its content is the same on two ticks,
+// so a file that is being written isn't parsed half-written.
+func (s *Server) watchSettings(ctx context.Context, path string, decode SettingsDecoder, interval time.Duration, data []byte) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var pending []byte
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		next, err := os.ReadFile(path)
+		if err != nil {
+			s.logger.Error("settings are not read", Field{Key: "path", Value: path}, Field{Key: "error", Value: err})
+			s.reportError(err)
+
+			continue
+		}
+
+		if bytes.Equal(next, data) {
+			pending = nil
+
+			continue
+		}
+
+		if pending == nil || !bytes.Equal(next, pending) {
+			pending = next
+
+			continue
+		}
+
+		data, pending = next, nil
+
+		err = s.applySettings(data, decode)
+		if err != nil {
+			s.logger.Error("settings are not applied", Field{Key: "path", Value: path}, Field{Key: "error", Value: err})
+			s.reportError(err)
+
+			continue
+		}
+
+		s.logger.Info("settings are applied", Field{Key: "path", Value: path})
+	}
+}
+
+func (s *Server) applySettings(data []byte, decode SettingsDecoder) (err error) {
+	var stg *ServerSettings
+	stg, err = ParseSettings(data, decode)
+	if err != nil {
+		return
+	}
+
+	err = s.SetSettings(stg)
+
+	return
+}
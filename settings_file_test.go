@@ -0,0 +1,347 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSettingsValidate(t *testing.T) {
+	for name, set := range map[string]func(stg *ServerSettings){
+		"read timeout": func(stg *ServerSettings) {
+			stg.SetReadTimeout(-time.Second)
+		},
+		"accept timeout": func(stg *ServerSettings) {
+			stg.SetAcceptQueue(1, -time.Second)
+		},
+		"compression": func(stg *ServerSettings) {
+			stg.SetCompression("unknown", 0)
+		},
+		"rate limit of \"report\" topic": func(stg *ServerSettings) {
+			stg.SetTopicRateLimit("report", -1, 1)
+		},
+		"access log sampling": func(stg *ServerSettings) {
+			stg.SetAccessLogSampling(2)
+		},
+	} {
+		stg := NewServerSettings()
+		set(stg)
+
+		err := stg.Validate()
+		if !errors.Is(err, InvalidSettings) {
+			t.Fatalf("invalid settings error of %s is expected, got %v", name, err)
+		}
+
+		var se *SettingsError
+		if !errors.As(err, &se) || se.Setting != name {
+			t.Fatalf("unexpected error of %s: %v", name, err)
+		}
+	}
+
+	err := NewServerSettings().Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetSettings(t *testing.T) {
+	server, err := NewServer(NewTCP("127.0.0.1", newTestPort(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prev, next *ServerSettings
+	server.SetSettingsHandler(func(p, n *ServerSettings) {
+		prev, next = p, n
+	})
+
+	settings := NewServerSettings()
+	settings.SetReadTimeout(time.Second)
+
+	err = server.SetSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if prev.read != DefaultConnTimeout || next.read != time.Second {
+		t.Fatalf("unexpected settings change %v -> %v", prev.read, next.read)
+	}
+
+	// applied settings are a copy
+	settings.SetReadTimeout(time.Minute)
+	if server.Settings().read != time.Second {
+		t.Fatal("applied settings are changed")
+	}
+
+	err = server.SetSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings.SetReadTimeout(-time.Second)
+
+	err = server.SetSettings(settings)
+	if !errors.Is(err, InvalidSettings) {
+		t.Fatalf("invalid settings error is expected, got %v", err)
+	}
+
+	if server.Settings().read != time.Minute || next.read != time.Minute {
+		t.Fatal("invalid settings are applied")
+	}
+
+	err = server.SetSettings(nil)
+	if !errors.Is(err, InvalidSettings) {
+		t.Fatalf("invalid settings error is expected, got %v", err)
+	}
+
+	// the handler gets a copy of applied settings
+	server.SetSettingsHandler(func(p, n *ServerSettings) {
+		n.SetMaxConns(42)
+	})
+
+	err = server.SetSettings(NewServerSettings())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if server.Settings().conns != 0 {
+		t.Fatal("applied settings are changed by the handler")
+	}
+}
+
+func TestSettingsLogLevel(t *testing.T) {
+	server, err := NewServer(NewTCP("127.0.0.1", newTestPort(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rl := &recordLogger{}
+	server.SetLogger(rl)
+
+	// peers log by copies of the server logger
+	logger := server.logger.with(Field{Key: "addr", Value: "127.0.0.1:8080"})
+
+	settings := NewServerSettings()
+	settings.SetLogLevel(ErrorLevel)
+
+	err = server.SetSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Warn("skipped")
+	logger.Error("failed")
+
+	if len(rl.lines) != 1 || rl.lines[0] != "ERROR failed addr=127.0.0.1:8080" {
+		t.Fatalf("unexpected lines %q", rl.lines)
+	}
+}
+
+func TestClientLogLevel(t *testing.T) {
+	client, err := NewClient(NewTCP("127.0.0.1", newTestPort(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rl := &recordLogger{}
+	client.SetLogger(rl)
+
+	settings := NewClientSettings()
+	settings.SetLogLevel(ErrorLevel)
+	client.SetSettings(settings)
+
+	client.logger.Warn("skipped")
+	client.logger.Error("failed")
+
+	if len(rl.lines) != 1 || rl.lines[0] != "ERROR failed" {
+		t.Fatalf("unexpected lines %q", rl.lines)
+	}
+}
+
+func TestParseSettings(t *testing.T) {
+	stg, err := ParseSettings([]byte(`{
+		"read_timeout": "1s",
+		"max_handlers": 8,
+		"topic_rate_limits": {"report.{id}": {"limit": 0.5, "burst": 2}},
+		"log_level": "warn"
+	}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stg.read != time.Second || stg.handlers != 8 || stg.level != WarnLevel ||
+		stg.RateLimits.topics["report.{id}"] != newRateLimit(0.5, 2) {
+		t.Fatalf("unexpected settings %+v", stg)
+	}
+
+	// missed settings keep default values
+	if stg.write != DefaultConnTimeout || stg.idle != DefaultIdleTimeout || stg.body != DefaultBodyLimit ||
+		stg.acceptTimeout != DefaultAcceptTimeout || stg.sampling != DefaultAccessLogSampling {
+		t.Fatalf("unexpected default settings %+v", stg)
+	}
+
+	_, err = ParseSettings([]byte(`{"log_level": "verbose"}`), nil)
+	if !errors.Is(err, UnsupportedLevel) {
+		t.Fatalf("unsupported level error is expected, got %v", err)
+	}
+
+	_, err = ParseSettings([]byte(`{"idle_timeout": "-1s"}`), nil)
+	if !errors.Is(err, InvalidSettings) {
+		t.Fatalf("invalid settings error is expected, got %v", err)
+	}
+}
+
+func TestWatchSettings(t *testing.T) {
+	server, err := NewServer(NewTCP("127.0.0.1", newTestPort(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	errs := make(chan error, 8)
+	server.SetErrorHandler(func(err error) {
+		errs <- err
+	})
+
+	path := filepath.Join(t.TempDir(), "settings.json")
+
+	// a file is replaced at once, so the watcher doesn't see it half-written
+	write := func(data string) {
+		tmp := path + ".tmp"
+
+		err := os.WriteFile(tmp, []byte(data), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.Rename(tmp, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"max_handlers": 1}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = server.WatchSettings(ctx, path, nil, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if server.Settings().handlers != 1 {
+		t.Fatalf("settings of the file are not applied")
+	}
+
+	write(`{"max_handlers": 2}`)
+
+	for i := 0; server.Settings().handlers != 2; i++ {
+		if i == 100 {
+			t.Fatal("changed settings are not applied")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	write(`{"max_handlers": 3, "read_timeout": "-1s"}`)
+
+	for !errors.Is(err, InvalidSettings) {
+		select {
+		case err = <-errs:
+		case <-time.After(time.Second):
+			t.Fatal("invalid settings error is not handled")
+		}
+	}
+
+	if server.Settings().handlers != 2 {
+		t.Fatal("invalid settings are applied")
+	}
+}
+
+func TestWatchSettingsPartialWrite(t *testing.T) {
+	server, err := NewServer(NewTCP("127.0.0.1", newTestPort(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	errs := make(chan error, 8)
+	server.SetErrorHandler(func(err error) {
+		errs <- err
+	})
+
+	path := filepath.Join(t.TempDir(), "settings.json")
+
+	err = os.WriteFile(path, []byte(`{"max_handlers": 1}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = server.WatchSettings(ctx, path, nil, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a file that is written in place is empty for a while
+	err = os.WriteFile(path, nil, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	err = os.WriteFile(path, []byte(`{"max_handlers": 2}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; server.Settings().handlers != 2; i++ {
+		if i == 100 {
+			t.Fatal("changed settings are not applied")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case err = <-errs:
+		t.Fatalf("unexpected error %v", err)
+	default:
+	}
+}
+
+func TestMaxConnsChange(t *testing.T) {
+	settings := NewServerSettings()
+	settings.SetMaxConns(1)
+	settings.SetAcceptQueue(0, 100*time.Millisecond)
+
+	server, entered, unblock := newOverloadTest(t, settings)
+	defer close(unblock)
+
+	blockHandler(t, server, entered)
+
+	_, err := newOverloadClient(t, server).Send("fast", Data{})
+	if !errors.Is(err, Overloaded) {
+		t.Fatalf("overloaded error is expected, got %v", err)
+	}
+
+	settings = server.Settings()
+	settings.SetMaxConns(2)
+
+	err = server.SetSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = newOverloadClient(t, server).Send("fast", Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,49 @@
+//go:build go1.21
+
+package p2p
+
+import (
+	"context"
+	"log/slog"
+)
+
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+var _ StructuredLogger = (*SlogLogger)(nil)
+
+func NewSlogLogger(logger *slog.Logger) (l *SlogLogger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &SlogLogger{
+		logger: logger,
+	}
+}
+
+func (l *SlogLogger) Enabled(level Level) (ok bool) {
+	return l.logger.Enabled(context.Background(), slog.Level(level))
+}
+
+func (l *SlogLogger) Log(level Level, msg string, fields ...Field) {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, field := range fields {
+		attrs = append(attrs, slog.Any(field.Key, field.Value))
+	}
+
+	l.logger.LogAttrs(context.Background(), slog.Level(level), msg, attrs...)
+}
+
+func (l *SlogLogger) Info(msg string) {
+	l.Log(InfoLevel, msg)
+}
+
+func (l *SlogLogger) Warn(msg string) {
+	l.Log(WarnLevel, msg)
+}
+
+func (l *SlogLogger) Error(msg string) {
+	l.Log(ErrorLevel, msg)
+}
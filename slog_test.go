@@ -0,0 +1,33 @@
+//go:build go1.21
+
+package p2p
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newFieldLogger(NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+	}))))
+
+	logger.Info("skipped")
+	logger.with(Field{Key: "addr", Value: "127.0.0.1:8080"}).Error("handler failed",
+		Field{Key: "topic", Value: "ping"}, Field{Key: "error", Value: errors.New("failed")})
+
+	line := buf.String()
+	if strings.Contains(line, "skipped") || strings.Count(line, "\n") != 1 {
+		t.Fatalf("level is not filtered: %s", line)
+	}
+
+	for _, part := range []string{"level=ERROR", `msg="handler failed"`, "addr=127.0.0.1:8080", "topic=ping", "error=failed"} {
+		if !strings.Contains(line, part) {
+			t.Fatalf("%s is not found in %s", part, line)
+		}
+	}
+}
@@ -6,7 +6,8 @@ import (
 
 type TCP struct {
 	addr      string
-	cipherKey *CipherKey
+	cipherKey *sessionKey
+	session   Session
 }
 
 func NewTCP(host, port string) (tcp *TCP) {
@@ -0,0 +1,246 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTimeoutServer(t *testing.T, settings *ServerSettings) (server *Server) {
+	port := newTestPort(t)
+
+	server, err := NewServer(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+	err = server.SetSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.SetHandler("slow", func(ctx context.Context, req Data) (res Data, err error) {
+		time.Sleep(400 * time.Millisecond)
+
+		return
+	})
+
+	server.SetHandler("ping", func(ctx context.Context, req Data) (res Data, err error) {
+		return
+	})
+
+	startTestServer(t, server)
+
+	return
+}
+
+// waitClosed returns how long the server keeps the connection open.
+func waitClosed(t *testing.T, conn net.Conn) (dur time.Duration) {
+	tm := time.Now()
+
+	err := conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = conn.Read(make([]byte, 1))
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("closed connection is expected, got %v", err)
+	}
+
+	return time.Since(tm)
+}
+
+func TestSlowHandler(t *testing.T) {
+	settings := NewServerSettings()
+	settings.SetHandleTimeout(time.Second)
+
+	server := newTimeoutServer(t, settings)
+
+	client, err := NewClient(newTestTCP(t, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	clientSettings := NewClientSettings()
+	clientSettings.SetRetry(1, 0)
+	client.SetSettings(clientSettings)
+
+	// the handler is longer than the read and write timeouts, which don't limit it anymore
+	_, err = client.Send("slow", Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientSettings.SetIdleTimeout(100 * time.Millisecond)
+
+	_, err = client.Send("slow", Data{})
+	if err == nil {
+		t.Fatal("idle timeout error is expected")
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	settings := NewServerSettings()
+	settings.SetIdleTimeout(50 * time.Millisecond)
+
+	server := newTimeoutServer(t, settings)
+
+	conn, err := net.Dial("tcp", server.tcp.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if dur := waitClosed(t, conn); dur > time.Second {
+		t.Fatalf("idle connection is closed after %v", dur)
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	settings := NewServerSettings()
+	settings.SetIdleTimeout(time.Minute)
+	settings.SetReadTimeout(50 * time.Millisecond)
+
+	server := newTimeoutServer(t, settings)
+
+	conn, err := net.Dial("tcp", server.tcp.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	// a frame header that isn't finished
+	_, err = conn.Write([]byte{FrameMagic})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dur := waitClosed(t, conn); dur > time.Second {
+		t.Fatalf("stalled connection is closed after %v", dur)
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	settings := NewServerSettings()
+	settings.SetIdleTimeout(time.Minute)
+	settings.SetReadTimeout(time.Minute)
+	settings.SetHandshakeTimeout(50 * time.Millisecond)
+
+	server := newTimeoutServer(t, settings)
+
+	conn, err := net.Dial("tcp", server.tcp.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	// Hello isn't sent
+	if dur := waitClosed(t, conn); dur > time.Second {
+		t.Fatalf("connection without Hello is closed after %v", dur)
+	}
+}
+
+func TestLinkIdle(t *testing.T) {
+	settings := NewServerSettings()
+	settings.SetIdleTimeout(50 * time.Millisecond)
+
+	server := newTimeoutServer(t, settings)
+
+	client, err := NewClient(newTestTCP(t, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	clientSettings := NewClientSettings()
+	clientSettings.SetIdleTimeout(50 * time.Millisecond)
+	client.SetSettings(clientSettings)
+
+	peer, err := client.Link()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = peer.Close()
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	_, err = peer.Send("ping", Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConnDeadline(t *testing.T) {
+	settings := NewClientSettings()
+	settings.SetHandshakeTimeout(time.Minute)
+
+	conn, err := NewConn(&bufferConn{}, settings.Limiter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deadline := conn.deadlineAfter(0); !deadline.IsZero() {
+		t.Fatalf("unexpected deadline %v", deadline)
+	}
+
+	conn.beginHandshake()
+	if deadline := conn.deadlineAfter(time.Hour); time.Until(deadline) > time.Minute {
+		t.Fatalf("deadline %v isn't limited by handshake", deadline)
+	}
+
+	limit := time.Now().Add(time.Second)
+	conn.limit(limit)
+	if deadline := conn.deadlineAfter(time.Hour); !deadline.Equal(limit) {
+		t.Fatalf("deadline %v isn't limited by %v", deadline, limit)
+	}
+
+	conn.endHandshake()
+	conn.link()
+	if deadline := conn.deadlineAfter(0); !deadline.IsZero() {
+		t.Fatalf("unexpected deadline %v of link", deadline)
+	}
+}
+
+func TestZeroHandleTimeout(t *testing.T) {
+	settings := NewServerSettings()
+	settings.SetHandleTimeout(0)
+
+	server := newTimeoutServer(t, settings)
+
+	server.SetHandler("deadline", func(ctx context.Context, req Data) (res Data, err error) {
+		_, ok := ctx.Deadline()
+		if ok || ctx.Err() != nil {
+			err = errors.New("handler is limited")
+		}
+
+		return
+	})
+
+	client, err := NewClient(newTestTCP(t, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	clientSettings := NewClientSettings()
+	clientSettings.SetRetry(1, 0)
+	client.SetSettings(clientSettings)
+
+	_, err = client.Send("deadline", Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
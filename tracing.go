@@ -0,0 +1,42 @@
+package p2p
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	TraceParentKey = "traceparent"
+	TraceStateKey  = "tracestate"
+)
+
+type SpanKind uint8
+
+const (
+	ClientSpan SpanKind = iota
+	ServerSpan
+)
+
+// Tracer starts spans of requests. A client span injects trace context
+// (traceparent and tracestate) into request metadata, a server span extracts it.
+type Tracer interface {
+	Start(ctx context.Context, kind SpanKind, topic string, md Metadata) (c context.Context, span Span)
+}
+
+// Span gets events of request phases (handshake, read, handle and write) with their durations.
+type Span interface {
+	AddEvent(name string, dur time.Duration)
+	End(err error)
+}
+
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, _ SpanKind, _ string, _ Metadata) (c context.Context, span Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) AddEvent(string, time.Duration) {}
+
+func (nopSpan) End(error) {}
@@ -0,0 +1,82 @@
+package otel
+
+import (
+	"context"
+	"time"
+
+	p2p "github.com/leprosus/golang-p2p"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const InstrumentationName = "github.com/leprosus/golang-p2p"
+
+const (
+	SystemKey   = attribute.Key("rpc.system")
+	MethodKey   = attribute.Key("rpc.method")
+	DurationKey = attribute.Key("p2p.duration")
+)
+
+type Tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+var _ p2p.Tracer = (*Tracer)(nil)
+
+// NewTracer uses the global tracer provider and W3C trace context propagator when they are nil.
+func NewTracer(provider trace.TracerProvider, propagator propagation.TextMapPropagator) (t *Tracer) {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+
+	if propagator == nil {
+		propagator = propagation.TraceContext{}
+	}
+
+	return &Tracer{
+		tracer:     provider.Tracer(InstrumentationName),
+		propagator: propagator,
+	}
+}
+
+func (t *Tracer) Start(ctx context.Context, kind p2p.SpanKind, topic string, md p2p.Metadata) (c context.Context, span p2p.Span) {
+	spanKind := trace.SpanKindClient
+	if kind == p2p.ServerSpan {
+		spanKind = trace.SpanKindServer
+		ctx = t.propagator.Extract(ctx, md)
+	}
+
+	var s trace.Span
+	c, s = t.tracer.Start(ctx, topic,
+		trace.WithSpanKind(spanKind),
+		trace.WithAttributes(SystemKey.String("p2p"), MethodKey.String(topic)))
+
+	if kind == p2p.ClientSpan && md != nil {
+		t.propagator.Inject(c, md)
+	}
+
+	return c, otelSpan{
+		span: s,
+	}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s otelSpan) AddEvent(name string, dur time.Duration) {
+	s.span.AddEvent(name, trace.WithAttributes(DurationKey.Float64(dur.Seconds())))
+}
+
+func (s otelSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+
+	s.span.End()
+}
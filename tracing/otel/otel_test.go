@@ -0,0 +1,56 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	p2p "github.com/leprosus/golang-p2p"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	tracer := NewTracer(provider, nil)
+
+	md := p2p.Metadata{}
+	_, clientSpan := tracer.Start(context.Background(), p2p.ClientSpan, "ping", md)
+
+	if md.Get(p2p.TraceParentKey) == "" {
+		t.Fatalf("trace context is not injected into %v", md)
+	}
+
+	ctx, serverSpan := tracer.Start(context.Background(), p2p.ServerSpan, "ping", md)
+	serverSpan.AddEvent("handle", time.Millisecond)
+	serverSpan.End(errors.New("failed"))
+	clientSpan.End(nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("unexpected spans %v", spans)
+	}
+
+	server, client := spans[0], spans[1]
+	if server.SpanKind() != trace.SpanKindServer || client.SpanKind() != trace.SpanKindClient {
+		t.Fatalf("unexpected span kinds %v and %v", server.SpanKind(), client.SpanKind())
+	}
+
+	if server.Parent().SpanID() != client.SpanContext().SpanID() ||
+		trace.SpanContextFromContext(ctx).TraceID() != client.SpanContext().TraceID() {
+		t.Fatal("server span is not a child of client span")
+	}
+
+	if len(server.Events()) != 2 || server.Events()[0].Name != "handle" || server.Events()[1].Name != "exception" {
+		t.Fatalf("unexpected events %v", server.Events())
+	}
+
+	if server.Status().Code != codes.Error || client.Status().Code != codes.Unset {
+		t.Fatalf("unexpected statuses %v and %v", server.Status(), client.Status())
+	}
+}
@@ -0,0 +1,161 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testSpan struct {
+	tracer *testTracer
+	kind   SpanKind
+	topic  string
+	parent string
+	events []string
+	err    error
+}
+
+func (s *testSpan) AddEvent(name string, _ time.Duration) {
+	s.tracer.mx.Lock()
+	s.events = append(s.events, name)
+	s.tracer.mx.Unlock()
+}
+
+func (s *testSpan) End(err error) {
+	s.tracer.mx.Lock()
+	s.err = err
+	s.tracer.ended = append(s.tracer.ended, s)
+	s.tracer.mx.Unlock()
+}
+
+type testTracer struct {
+	mx    sync.Mutex
+	ended []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, kind SpanKind, topic string, md Metadata) (c context.Context, span Span) {
+	s := &testSpan{
+		tracer: t,
+		kind:   kind,
+		topic:  topic,
+	}
+
+	if kind == ClientSpan {
+		md.Set(TraceParentKey, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	} else {
+		s.parent = md.Get(TraceParentKey)
+	}
+
+	return ctx, s
+}
+
+func TestTracing(t *testing.T) {
+	port := newTestPort(t)
+
+	server, err := NewServer(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	serverTracer := &testTracer{}
+	server.SetTracer(serverTracer)
+
+	traceParents := make(chan string, 1)
+	server.SetHandler("ping", func(ctx context.Context, req Data) (res Data, err error) {
+		traceParents <- IncomingMetadata(ctx).Get(TraceParentKey)
+
+		return
+	})
+
+	startTestServer(t, server)
+
+	client, err := NewClient(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	clientTracer := &testTracer{}
+	client.SetTracer(clientTracer)
+
+	md := Metadata{"key": "value"}
+	_, _, err = client.SendWithMetadata("ping", Data{}, md)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if md.Get(TraceParentKey) != "" {
+		t.Fatal("request metadata is changed")
+	}
+
+	if <-traceParents == "" {
+		t.Fatal("trace context is not propagated")
+	}
+
+	if len(clientTracer.ended) != 1 {
+		t.Fatalf("unexpected client spans %v", clientTracer.ended)
+	}
+
+	span := clientTracer.ended[0]
+	if span.kind != ClientSpan || span.topic != "ping" || span.err != nil ||
+		!equalStrings(span.events, []string{"handshake", "write", "read"}) {
+		t.Fatalf("unexpected client span %+v", span)
+	}
+
+	for i := 0; i < 100; i++ {
+		serverTracer.mx.Lock()
+		n := len(serverTracer.ended)
+		serverTracer.mx.Unlock()
+
+		if n > 0 {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	serverTracer.mx.Lock()
+	defer serverTracer.mx.Unlock()
+
+	if len(serverTracer.ended) != 1 {
+		t.Fatalf("unexpected server spans %v", serverTracer.ended)
+	}
+
+	span = serverTracer.ended[0]
+	if span.kind != ServerSpan || span.parent == "" ||
+		!equalStrings(span.events, []string{"handshake", "read", "handle", "write"}) {
+		t.Fatalf("unexpected server span %+v", span)
+	}
+}
+
+func TestSendContext(t *testing.T) {
+	client, err := NewClient(NewTCP("127.0.0.1", newTestPort(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err = client.SendContext(ctx, "ping", Data{}, nil)
+	if err != context.Canceled {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func equalStrings(a, b []string) (ok bool) {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,111 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+type HandlerSetter interface {
+	SetHandler(topic string, handler Handler)
+}
+
+type Sender interface {
+	Send(topic string, req Data) (res Data, err error)
+}
+
+// ContextSender is a Sender that sends requests as a part of a context, e.g. a client or a peer.
+type ContextSender interface {
+	SendContext(ctx context.Context, topic string, req Data, md Metadata) (res Data, resMD Metadata, err error)
+}
+
+// sendContext sends a request by SendContext when the sender supports it, others ignore ctx.
+func sendContext(ctx context.Context, sender Sender, topic string, req Data) (res Data, err error) {
+	cs, ok := sender.(ContextSender)
+	if !ok {
+		return sender.Send(topic, req)
+	}
+
+	res, _, err = cs.SendContext(ctx, topic, req, nil)
+
+	return
+}
+
+type DecodeError struct {
+	Topic string
+	Codec string
+	Err   error
+}
+
+func (e *DecodeError) Error() (str string) {
+	return fmt.Sprintf("decode %s (%s): %s", e.Topic, e.Codec, e.Err.Error())
+}
+
+func (e *DecodeError) Unwrap() (err error) {
+	return e.Err
+}
+
+func HandleTyped[Req, Res any](setter HandlerSetter, topic string, handler func(ctx context.Context, req Req) (res Res, err error)) {
+	setter.SetHandler(topic, func(ctx context.Context, in Data) (out Data, err error) {
+		var req Req
+		err = in.Decode(&req)
+		if err != nil {
+			err = &DecodeError{
+				Topic: topic,
+				Codec: in.ContentType,
+				Err:   err,
+			}
+
+			return
+		}
+
+		var res Res
+		res, err = handler(ctx, req)
+		if err != nil {
+			return
+		}
+
+		err = out.Encode(in.ContentType, res)
+
+		return
+	})
+
+	setSchema(setter, topic, reflect.TypeOf((*Req)(nil)).Elem(), reflect.TypeOf((*Res)(nil)).Elem())
+}
+
+func Call[Req, Res any](sender Sender, topic string, req Req) (res Res, err error) {
+	return CallCodecContext[Req, Res](context.Background(), sender, GobCodecName, topic, req)
+}
+
+func CallContext[Req, Res any](ctx context.Context, sender Sender, topic string, req Req) (res Res, err error) {
+	return CallCodecContext[Req, Res](ctx, sender, GobCodecName, topic, req)
+}
+
+func CallCodec[Req, Res any](sender Sender, codec, topic string, req Req) (res Res, err error) {
+	return CallCodecContext[Req, Res](context.Background(), sender, codec, topic, req)
+}
+
+func CallCodecContext[Req, Res any](ctx context.Context, sender Sender, codec, topic string, req Req) (res Res, err error) {
+	var in Data
+	err = in.Encode(codec, req)
+	if err != nil {
+		return
+	}
+
+	var out Data
+	out, err = sendContext(ctx, sender, topic, in)
+	if err != nil {
+		return
+	}
+
+	err = out.Decode(&res)
+	if err != nil {
+		err = &DecodeError{
+			Topic: topic,
+			Codec: out.ContentType,
+			Err:   err,
+		}
+	}
+
+	return
+}
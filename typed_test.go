@@ -0,0 +1,137 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type typedHello struct {
+	Text string
+}
+
+type typedBuy struct {
+	Text string
+}
+
+func TestTyped(t *testing.T) {
+	port := newTestPort(t)
+
+	server, err := NewServer(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	HandleTyped(server, "dialog", func(ctx context.Context, req typedHello) (res typedBuy, err error) {
+		res.Text = strings.ToUpper(req.Text)
+
+		return
+	})
+
+	startTestServer(t, server)
+
+	client, err := NewClient(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	settings := NewClientSettings()
+	settings.SetCompression(GzipCompressorName, 0)
+	client.SetSettings(settings)
+
+	for _, codec := range []string{GobCodecName, JsonCodecName} {
+		var res typedBuy
+		res, err = CallCodec[typedHello, typedBuy](client, codec, "dialog", typedHello{Text: codec})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Text != strings.ToUpper(codec) {
+			t.Fatalf("unexpected response %q", res.Text)
+		}
+	}
+
+	var res Data
+	res, err = client.Send("dialog", Data{Bytes: []byte("raw")})
+	if err == nil {
+		t.Fatalf("unexpected response %q", res.String())
+	}
+
+	if !strings.Contains(err.Error(), UnsupportedCodec.Error()) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	_, err := CallCodec[typedHello, typedBuy](senderFunc(func(topic string, req Data) (res Data, err error) {
+		res.SetBytes([]byte("raw"))
+
+		return
+	}), JsonCodecName, "dialog", typedHello{})
+
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Topic != "dialog" || !errors.Is(err, UnsupportedCodec) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestCallContext(t *testing.T) {
+	server, err := NewServer(NewTCP("127.0.0.1", newTestPort(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	settings := NewServerSettings()
+	settings.SetHandleTimeout(time.Second)
+	err = server.SetSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+
+	HandleTyped(server, "block", func(ctx context.Context, req typedHello) (res typedBuy, err error) {
+		<-release
+
+		return
+	})
+
+	startTestServer(t, server)
+
+	client, err := NewClient(newTestTCP(t, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLogger(nopLogger{})
+
+	clientSettings := NewClientSettings()
+	clientSettings.SetConnTimeout(time.Second)
+	clientSettings.SetRetry(1, 0)
+	client.SetSettings(clientSettings)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	tm := time.Now()
+
+	_, err = CallContext[typedHello, typedBuy](ctx, client, "block", typedHello{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if dur := time.Since(tm); dur > 500*time.Millisecond {
+		t.Fatalf("call isn't canceled: %v", dur)
+	}
+}
+
+type senderFunc func(topic string, req Data) (res Data, err error)
+
+func (f senderFunc) Send(topic string, req Data) (res Data, err error) {
+	return f(topic, req)
+}
@@ -0,0 +1,264 @@
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+)
+
+type WireCodec interface {
+	Name() (name string)
+	WritePackage(w io.Writer, p Package) (err error)
+	ReadPackage(r io.Reader, p *Package) (err error)
+	Marshal(val interface{}) (d Data, err error)
+	Unmarshal(d Data, val interface{}) (err error)
+}
+
+const (
+	GobWireName   = "gob"
+	FrameWireName = "frame"
+)
+
+var wires = map[string]WireCodec{
+	GobWireName:   GobWire{},
+	FrameWireName: FrameWire{},
+}
+
+func GetWireCodec(name string) (wire WireCodec, ok bool) {
+	wire, ok = wires[name]
+
+	return
+}
+
+type GobWire struct{}
+
+func (GobWire) Name() (name string) {
+	return GobWireName
+}
+
+func (GobWire) WritePackage(w io.Writer, p Package) (err error) {
+	err = gob.NewEncoder(w).Encode(p)
+
+	return
+}
+
+func (GobWire) ReadPackage(r io.Reader, p *Package) (err error) {
+	err = gob.NewDecoder(r).Decode(p)
+
+	return
+}
+
+func (GobWire) Marshal(val interface{}) (d Data, err error) {
+	err = d.SetGob(val)
+
+	return
+}
+
+func (GobWire) Unmarshal(d Data, val interface{}) (err error) {
+	err = d.GetGob(val)
+
+	return
+}
+
+const (
+	FrameMagic      byte = 0xF0
+	FrameVersion    byte = 1
+	FrameHeaderSize      = 16
+	MaxFramePayload      = 16 << 20
+
+	// frameChunk is a size of a payload that is allocated at once,
+	// larger payloads grow as data arrives, so a forged size doesn't allocate memory.
+	frameChunk = 64 << 10
+)
+
+const (
+	FlagJSON byte = 1 << iota
+)
+
+type FrameWire struct{}
+
+func (FrameWire) Name() (name string) {
+	return FrameWireName
+}
+
+func (FrameWire) WritePackage(w io.Writer, p Package) (err error) {
+	if len(p.Bytes) > MaxFramePayload {
+		err = FrameSizeError
+
+		return
+	}
+
+	header := make([]byte, FrameHeaderSize)
+	header[0] = FrameMagic
+	header[1] = FrameVersion
+	header[2] = byte(p.Type)
+	if p.ContentType == JsonCodecName {
+		header[3] |= FlagJSON
+	}
+	binary.BigEndian.PutUint32(header[4:8], uint32(len(p.Bytes)))
+	binary.BigEndian.PutUint64(header[8:16], p.ID)
+
+	_, err = w.Write(header)
+	if err != nil {
+		return
+	}
+
+	_, err = w.Write(p.Bytes)
+
+	return
+}
+
+func (FrameWire) ReadPackage(r io.Reader, p *Package) (err error) {
+	header := make([]byte, FrameHeaderSize)
+	_, err = io.ReadFull(r, header)
+	if err != nil {
+		return
+	}
+
+	if header[0] != FrameMagic || header[1] != FrameVersion {
+		err = UnsupportedFrame
+
+		return
+	}
+
+	size := binary.BigEndian.Uint32(header[4:8])
+	if size > MaxFramePayload {
+		err = FrameSizeError
+
+		return
+	}
+
+	*p = Package{
+		Type: PackageType(header[2]),
+		ID:   binary.BigEndian.Uint64(header[8:16]),
+	}
+
+	if header[3]&FlagJSON != 0 {
+		p.ContentType = JsonCodecName
+	}
+
+	p.Bytes, err = readPayload(r, int64(size))
+
+	return
+}
+
+func readPayload(r io.Reader, size int64) (bs []byte, err error) {
+	if size <= frameChunk {
+		bs = make([]byte, size)
+		_, err = io.ReadFull(r, bs)
+
+		return
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, frameChunk))
+
+	_, err = io.CopyN(buf, r, size)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	if err != nil {
+		return
+	}
+
+	bs = buf.Bytes()
+
+	return
+}
+
+type frameMessage struct {
+	Topic       string   `json:"topic"`
+	Content     []byte   `json:"content,omitempty"`
+	ContentType string   `json:"content_type,omitempty"`
+	Compression string   `json:"compression,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	Metadata    Metadata `json:"metadata,omitempty"`
+}
+
+func (FrameWire) Marshal(val interface{}) (d Data, err error) {
+	switch v := val.(type) {
+	case CryptMessage:
+		d.SetBytes(v)
+
+		return
+	case Message:
+		fm := frameMessage{
+			Topic:       v.Topic,
+			Content:     v.Content,
+			ContentType: v.ContentType,
+			Compression: v.Compression,
+			Metadata:    v.Metadata,
+		}
+
+		if v.Error != nil {
+			fm.Error = v.Error.Error()
+		}
+
+		val = fm
+	}
+
+	var bs []byte
+	bs, err = json.Marshal(val)
+	if err != nil {
+		return
+	}
+
+	d = Data{
+		Bytes:       bs,
+		ContentType: JsonCodecName,
+	}
+
+	return
+}
+
+func (FrameWire) Unmarshal(d Data, val interface{}) (err error) {
+	switch v := val.(type) {
+	case *CryptMessage:
+		*v = d.Bytes
+
+		return
+	case *Message:
+		var fm frameMessage
+		err = json.Unmarshal(d.Bytes, &fm)
+		if err != nil {
+			return
+		}
+
+		*v = Message{
+			Topic:       fm.Topic,
+			Content:     fm.Content,
+			ContentType: fm.ContentType,
+			Compression: fm.Compression,
+			Metadata:    fm.Metadata,
+		}
+
+		if fm.Error != "" {
+			v.Error = RemoteError{
+				Text: fm.Error,
+			}
+		}
+
+		return
+	}
+
+	err = json.Unmarshal(d.Bytes, val)
+
+	return
+}
+
+func detectWire(r *bufio.Reader) (wire WireCodec, err error) {
+	var first []byte
+	first, err = r.Peek(1)
+	if err != nil {
+		return
+	}
+
+	if first[0] == FrameMagic {
+		return FrameWire{}, nil
+	}
+
+	return GobWire{}, nil
+}
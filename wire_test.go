@@ -0,0 +1,195 @@
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+)
+
+func TestFrameWire(t *testing.T) {
+	in := Package{
+		Type: Reply,
+		ID:   42,
+		Data: Data{
+			Bytes:       []byte(`{"topic":"ping"}`),
+			ContentType: JsonCodecName,
+		},
+	}
+
+	var buf bytes.Buffer
+	err := FrameWire{}.WritePackage(&buf, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := buf.Bytes()[:FrameHeaderSize]
+	expected := []byte{FrameMagic, FrameVersion, byte(Reply), FlagJSON, 0, 0, 0, 16, 0, 0, 0, 0, 0, 0, 0, 42}
+	if !bytes.Equal(header, expected) {
+		t.Fatalf("unexpected header % x", header)
+	}
+
+	reader := bufio.NewReader(&buf)
+
+	wire, err := detectWire(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if wire.Name() != FrameWireName {
+		t.Fatalf("unexpected wire %s", wire.Name())
+	}
+
+	var out Package
+	err = wire.ReadPackage(reader, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Type != in.Type || out.ID != in.ID || out.ContentType != in.ContentType || !bytes.Equal(out.Bytes, in.Bytes) {
+		t.Fatalf("unexpected package %v", out)
+	}
+
+	buf.Reset()
+	buf.Write([]byte{FrameMagic, FrameVersion, byte(Exchange), 0, 0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0, 0, 0, 0, 1})
+
+	err = FrameWire{}.ReadPackage(&buf, &out)
+	if !errors.Is(err, FrameSizeError) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestFramePayload(t *testing.T) {
+	payload := bytes.Repeat([]byte("payload "), frameChunk)
+
+	var buf bytes.Buffer
+	err := FrameWire{}.WritePackage(&buf, Package{Type: Exchange, Data: Data{Bytes: payload}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out Package
+	err = FrameWire{}.ReadPackage(&buf, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out.Bytes, payload) {
+		t.Fatal("Origin and Read payloads are not equal")
+	}
+
+	// a header of a max payload that isn't sent
+	buf.Reset()
+	buf.Write([]byte{FrameMagic, FrameVersion, byte(Exchange), 0, 0x01, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1})
+	buf.Write([]byte("short"))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	err = FrameWire{}.ReadPackage(&buf, &out)
+
+	runtime.ReadMemStats(&after)
+
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Fatalf("%d bytes are allocated for a forged size", allocated)
+	}
+}
+
+func TestFrameWireMessage(t *testing.T) {
+	in := Message{
+		Topic:       "ping",
+		Content:     []byte("pong"),
+		ContentType: JsonCodecName,
+		Error:       UnsupportedTopic,
+		Metadata:    Metadata{"trace-id": "42"},
+	}
+
+	d, err := FrameWire{}.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out Message
+	err = FrameWire{}.Unmarshal(d, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Topic != in.Topic || string(out.Content) != "pong" || out.Metadata.Get("trace-id") != "42" ||
+		!errors.Is(out.Error, UnsupportedTopic) {
+		t.Fatalf("unexpected message %v", out)
+	}
+}
+
+func TestFrameWireExchange(t *testing.T) {
+	port := newTestPort(t)
+
+	server, err := NewServer(NewTCP("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogger(nopLogger{})
+
+	server.SetHandler("ping", func(ctx context.Context, req Data) (res Data, err error) {
+		res.SetBytes(append([]byte("pong "), req.GetBytes()...))
+
+		return
+	})
+
+	startTestServer(t, server)
+
+	for _, name := range []string{GobWireName, FrameWireName} {
+		client, err := NewClient(NewTCP("127.0.0.1", port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.SetLogger(nopLogger{})
+
+		settings := NewClientSettings()
+		settings.SetWireCodec(name)
+		client.SetSettings(settings)
+
+		var req, res Data
+		req.SetBytes([]byte(name))
+
+		res, err = client.Send("ping", req)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if res.String() != "pong "+name {
+			t.Fatalf("%s: unexpected response %q", name, res.String())
+		}
+
+		peer, err := client.Link()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		res, err = peer.Send("ping", req)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if res.String() != "pong "+name {
+			t.Fatalf("%s: unexpected response %q", name, res.String())
+		}
+
+		_, err = peer.Send("unknown", req)
+		if !errors.Is(err, UnsupportedTopic) {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+
+		err = peer.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}